@@ -0,0 +1,321 @@
+package core
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// typeTag identifies how a Type was written to the wire, so DecodeType can
+// reconstruct it without sharing any state with the TypeMap that encoded
+// it: composite tags recurse into their element types, and tagNamed defers
+// to whatever IsType is registered under the same Name on the destination
+// TypeMap (see TypeMap.ByName) -- there's no way to rebuild a custom type
+// from scratch without the original IsType instance.
+type typeTag byte
+
+const (
+	tagInt32 typeTag = iota
+	tagInt64
+	tagInt
+	tagStr
+	tagTuple
+	tagArray
+	tagSlice
+	tagRef
+	tagStruct
+	tagNamed
+)
+
+// EncodeType writes a self-describing descriptor for typ to w. Builtin
+// scalars and the composite types in composite.go are written structurally;
+// anything else is written by Name alone, which DecodeType resolves via
+// TypeMap.ByName on whatever TypeMap it's given.
+func EncodeType(typ Type, w io.Writer) error {
+	switch def := typ.Def().(type) {
+	case intType:
+		switch def.name {
+		case "i32":
+			return writeTag(w, tagInt32)
+		case "i64":
+			return writeTag(w, tagInt64)
+		default:
+			return writeTag(w, tagInt)
+		}
+	case strType:
+		return writeTag(w, tagStr)
+	case Tuple:
+		if err := writeTag(w, tagTuple); err != nil {
+			return err
+		}
+		return encodeTypeKey(def.elems, w)
+	case Array:
+		if err := writeTag(w, tagArray); err != nil {
+			return err
+		}
+		if err := writeUvarint(w, uint64(def.Size)); err != nil {
+			return err
+		}
+		return EncodeType(def.Elem, w)
+	case Slice:
+		if err := writeTag(w, tagSlice); err != nil {
+			return err
+		}
+		return EncodeType(def.Elem, w)
+	case Ref:
+		if err := writeTag(w, tagRef); err != nil {
+			return err
+		}
+		return EncodeType(def.Elem, w)
+	case Struct:
+		if err := writeTag(w, tagStruct); err != nil {
+			return err
+		}
+		if err := writeUvarint(w, uint64(len(def.Fields))); err != nil {
+			return err
+		}
+		for _, f := range def.Fields {
+			if err := writeString(w, f.Name); err != nil {
+				return err
+			}
+			if err := EncodeType(f.Type, w); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		if err := writeTag(w, tagNamed); err != nil {
+			return err
+		}
+		return writeString(w, typ.Name())
+	}
+}
+
+func encodeTypeKey(key TypeKey, w io.Writer) error {
+	if err := writeUvarint(w, uint64(key.Len())); err != nil {
+		return err
+	}
+	for i := 0; i < key.Len(); i++ {
+		if err := EncodeType(key.Get(i), w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DecodeType reads a descriptor written by EncodeType and interns the
+// equivalent Type into dst.
+func DecodeType(dst *TypeMap, r io.Reader) (Type, error) {
+	tag, err := readTag(r)
+	if err != nil {
+		return Type{}, err
+	}
+
+	switch tag {
+	case tagInt32:
+		return dst.Int32(), nil
+	case tagInt64:
+		return dst.Int64(), nil
+	case tagInt:
+		return dst.Int(), nil
+	case tagStr:
+		return dst.Str(), nil
+	case tagTuple:
+		elems, err := decodeTypeList(dst, r)
+		if err != nil {
+			return Type{}, err
+		}
+		return dst.TupleOf(elems...), nil
+	case tagArray:
+		size, err := readUvarint(r)
+		if err != nil {
+			return Type{}, err
+		}
+		elem, err := DecodeType(dst, r)
+		if err != nil {
+			return Type{}, err
+		}
+		return dst.ArrayOf(elem, int(size)), nil
+	case tagSlice:
+		elem, err := DecodeType(dst, r)
+		if err != nil {
+			return Type{}, err
+		}
+		return dst.SliceOf(elem), nil
+	case tagRef:
+		elem, err := DecodeType(dst, r)
+		if err != nil {
+			return Type{}, err
+		}
+		return dst.RefOf(elem), nil
+	case tagStruct:
+		n, err := readUvarint(r)
+		if err != nil {
+			return Type{}, err
+		}
+		fields := make([]StructField, n)
+		for i := range fields {
+			name, err := readString(r)
+			if err != nil {
+				return Type{}, err
+			}
+			ftyp, err := DecodeType(dst, r)
+			if err != nil {
+				return Type{}, err
+			}
+			fields[i] = StructField{Name: name, Type: ftyp}
+		}
+		return dst.StructOf(fields...), nil
+	case tagNamed:
+		name, err := readString(r)
+		if err != nil {
+			return Type{}, err
+		}
+		if typ, ok := dst.ByName(name); ok {
+			return typ, nil
+		}
+		return Type{}, fmt.Errorf("codec: type `%s` is not registered on the destination TypeMap", name)
+	default:
+		return Type{}, fmt.Errorf("codec: unknown type tag %d", tag)
+	}
+}
+
+func decodeTypeList(dst *TypeMap, r io.Reader) ([]Type, error) {
+	n, err := readUvarint(r)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]Type, n)
+	for i := range out {
+		if out[i], err = DecodeType(dst, r); err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+// EncodeValue writes v to w as a type descriptor (EncodeType) followed by
+// the tagged payload, so DecodeValue can reconstruct an equivalent Value
+// against any TypeMap -- keyed by Type.Hash() in the sense that decoding
+// re-interns the type rather than trusting the encoder's TypeMap identity.
+func EncodeValue(v Value, w io.Writer) error {
+	if err := EncodeType(v.Type(), w); err != nil {
+		return err
+	}
+
+	switch def := v.Type().Def().(type) {
+	case intType:
+		return writeVarint(w, v.AsInt64())
+	case strType:
+		s, _ := v.Any().(string)
+		return writeString(w, s)
+	default:
+		if impl, ok := def.(CanEncode); ok {
+			return impl.EncodeValue(v, w)
+		}
+		return fmt.Errorf("codec: type `%s` does not support encoding", v.Type())
+	}
+}
+
+// DecodeValue reads a Value written by EncodeValue, interning its type into
+// dst. A type with no CanDecode implementation (and no builtin support)
+// fails the same way an unsupported EncodeValue does.
+func DecodeValue(dst *TypeMap, r io.Reader) (Value, error) {
+	typ, err := DecodeType(dst, r)
+	if err != nil {
+		return Value{}, err
+	}
+
+	switch def := typ.Def().(type) {
+	case intType:
+		n, err := readVarint(r)
+		if err != nil {
+			return Value{}, err
+		}
+		return NewValue(typ, n), nil
+	case strType:
+		s, err := readString(r)
+		if err != nil {
+			return Value{}, err
+		}
+		return NewValue(typ, s), nil
+	default:
+		impl, ok := def.(CanDecode)
+		if !ok {
+			return Value{}, fmt.Errorf("codec: type `%s` does not support decoding", typ)
+		}
+		val, err := impl.DecodeValue(typ, r)
+		if err != nil {
+			return Value{}, err
+		}
+		return Value{typ, val}, nil
+	}
+}
+
+func writeTag(w io.Writer, tag typeTag) error {
+	_, err := w.Write([]byte{byte(tag)})
+	return err
+}
+
+func readTag(r io.Reader) (typeTag, error) {
+	var buf [1]byte
+	if _, err := io.ReadFull(r, buf[:]); err != nil {
+		return 0, err
+	}
+	return typeTag(buf[0]), nil
+}
+
+func writeUvarint(w io.Writer, v uint64) error {
+	var buf [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(buf[:], v)
+	_, err := w.Write(buf[:n])
+	return err
+}
+
+func readUvarint(r io.Reader) (uint64, error) {
+	return binary.ReadUvarint(byteReader{r})
+}
+
+func writeVarint(w io.Writer, v int64) error {
+	var buf [binary.MaxVarintLen64]byte
+	n := binary.PutVarint(buf[:], v)
+	_, err := w.Write(buf[:n])
+	return err
+}
+
+func readVarint(r io.Reader) (int64, error) {
+	return binary.ReadVarint(byteReader{r})
+}
+
+func writeString(w io.Writer, s string) error {
+	if err := writeUvarint(w, uint64(len(s))); err != nil {
+		return err
+	}
+	_, err := w.Write([]byte(s))
+	return err
+}
+
+func readString(r io.Reader) (string, error) {
+	n, err := readUvarint(r)
+	if err != nil {
+		return "", err
+	}
+	buf := make([]byte, n)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return "", err
+	}
+	return string(buf), nil
+}
+
+// byteReader adapts an io.Reader to io.ByteReader, which binary.ReadUvarint
+// and binary.ReadVarint need to read one byte at a time until the varint's
+// continuation bit is clear.
+type byteReader struct {
+	io.Reader
+}
+
+func (b byteReader) ReadByte() (byte, error) {
+	var buf [1]byte
+	_, err := io.ReadFull(b, buf[:])
+	return buf[0], err
+}
@@ -0,0 +1,75 @@
+package core_test
+
+import (
+	"bytes"
+	"testing"
+
+	"axlab.dev/byte/pkg/core"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncodeDecodeValueRoundTripsInt(t *testing.T) {
+	test := require.New(t)
+
+	src := core.TypeMap{}
+	buf := bytes.Buffer{}
+	test.NoError(core.EncodeValue(src.NewInt(42), &buf))
+
+	dst := core.TypeMap{}
+	out, err := core.DecodeValue(&dst, &buf)
+	test.NoError(err)
+	test.Equal(dst.Int(), out.Type())
+	test.Equal(42, out.AsInt())
+}
+
+func TestEncodeDecodeValueRoundTripsString(t *testing.T) {
+	test := require.New(t)
+
+	src := core.TypeMap{}
+	buf := bytes.Buffer{}
+	test.NoError(core.EncodeValue(core.NewValue(src.Str(), "hello"), &buf))
+
+	dst := core.TypeMap{}
+	out, err := core.DecodeValue(&dst, &buf)
+	test.NoError(err)
+	test.Equal(dst.Str(), out.Type())
+	test.Equal("hello", out.Any())
+}
+
+func TestEncodeDecodeTypeRoundTripsComposites(t *testing.T) {
+	test := require.New(t)
+
+	src := core.TypeMap{}
+	typ := src.StructOf(
+		core.StructField{Name: "id", Type: src.Int64()},
+		core.StructField{Name: "tags", Type: src.SliceOf(src.Str())},
+		core.StructField{Name: "pair", Type: src.TupleOf(src.Int32(), src.ArrayOf(src.Int(), 3))},
+	)
+
+	buf := bytes.Buffer{}
+	test.NoError(core.EncodeType(typ, &buf))
+
+	dst := core.TypeMap{}
+	out, err := core.DecodeType(&dst, &buf)
+	test.NoError(err)
+	test.Equal(typ.String(), out.String())
+}
+
+func TestDecodeTypeFailsForUnregisteredNamedType(t *testing.T) {
+	test := require.New(t)
+
+	src := core.TypeMap{}
+	buf := bytes.Buffer{}
+	test.NoError(core.EncodeType(src.Intern(namedOnly{}), &buf))
+
+	dst := core.TypeMap{}
+	_, err := core.DecodeType(&dst, &buf)
+	test.Error(err)
+}
+
+// namedOnly is a Type with a non-empty Name but no CanCreate, just to
+// exercise the tagNamed path without pulling in another package.
+type namedOnly struct{}
+
+func (namedOnly) Name() string { return "NamedOnly" }
+func (namedOnly) Repr() string { return "NamedOnly" }
@@ -0,0 +1,98 @@
+package core
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Composite IsType definitions for the structural types built on top of a
+// TypeMap's interning -- arrays, slices, references and structs -- mirroring
+// Tuple's shape in type.go: each wraps just enough to produce a unique
+// Name/Repr pair, and is interned like any other IsType.
+
+type Array struct {
+	Elem Type
+	Size int
+}
+
+func (t Array) Name() string {
+	return ""
+}
+
+func (t Array) Repr() string {
+	return fmt.Sprintf("[%d]%s", t.Size, t.Elem.String())
+}
+
+// ArrayOf returns the Type for a fixed-size array of size elements of elem.
+func (m *TypeMap) ArrayOf(elem Type, size int) Type {
+	return m.Intern(Array{Elem: elem, Size: size})
+}
+
+type Slice struct {
+	Elem Type
+}
+
+func (t Slice) Name() string {
+	return ""
+}
+
+func (t Slice) Repr() string {
+	return "[]" + t.Elem.String()
+}
+
+// SliceOf returns the Type for a dynamically-sized slice of elem.
+func (m *TypeMap) SliceOf(elem Type) Type {
+	return m.Intern(Slice{Elem: elem})
+}
+
+type Ref struct {
+	Elem Type
+}
+
+func (t Ref) Name() string {
+	return ""
+}
+
+func (t Ref) Repr() string {
+	return "&" + t.Elem.String()
+}
+
+// RefOf returns the Type for a reference to elem.
+func (m *TypeMap) RefOf(elem Type) Type {
+	return m.Intern(Ref{Elem: elem})
+}
+
+// StructField names one field of a StructOf type.
+type StructField struct {
+	Name string
+	Type Type
+}
+
+type Struct struct {
+	Fields []StructField
+}
+
+func (t Struct) Name() string {
+	return ""
+}
+
+func (t Struct) Repr() string {
+	repr := strings.Builder{}
+	repr.WriteString("{")
+	for i, f := range t.Fields {
+		if i > 0 {
+			repr.WriteString(", ")
+		}
+		repr.WriteString(f.Name)
+		repr.WriteString(": ")
+		repr.WriteString(f.Type.String())
+	}
+	repr.WriteString("}")
+	return repr.String()
+}
+
+// StructOf returns the Type for an anonymous struct with the given fields,
+// in order.
+func (m *TypeMap) StructOf(fields ...StructField) Type {
+	return m.Intern(Struct{Fields: fields})
+}
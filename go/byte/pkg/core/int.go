@@ -16,15 +16,15 @@ func (m *TypeMap) NewInt(val int) Value {
 }
 
 func (m *TypeMap) Int() Type {
-	return m.Get(_int)
+	return m.Intern(_int)
 }
 
 func (m *TypeMap) Int32() Type {
-	return m.Get(_i32)
+	return m.Intern(_i32)
 }
 
 func (m *TypeMap) Int64() Type {
-	return m.Get(_i64)
+	return m.Intern(_i64)
 }
 
 type intType struct {
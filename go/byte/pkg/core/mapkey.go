@@ -0,0 +1,61 @@
+package core
+
+import (
+	"fmt"
+	"hash/fnv"
+)
+
+// equalFn and hashFn are installed lazily on a Type the first time it's
+// used as a map key -- see TypeMap.AsMapKey -- so a TypeMap doesn't pay for
+// them on every type it interns, most of which are never hashed.
+type equalFn = func(a, b Value) bool
+type hashFn = func(v Value) uint64
+
+// AsMapKey returns the equality and hash functions for values of type t,
+// installing them into t's typeData the first time t is used as a map key.
+//
+// Most types -- anything whose Value holds a Go-comparable val, like int or
+// String -- get a direct equal/hash pair computed straight from the value.
+// Types whose Def implements CanCompare have no such native comparability:
+// equality there dispatches through Value.Compare (the same mechanism
+// AddCompare installs pairwise comparators into), and the hash falls back to
+// hashing the value's Debug representation. This mirrors the extra
+// indirection a Go interface-typed map key pays relative to a concrete one.
+func (m *TypeMap) AsMapKey(t Type) (equal equalFn, hash hashFn) {
+	m.typeMapRw.Lock()
+	defer m.typeMapRw.Unlock()
+
+	data := t.data
+	if data.keyEqual == nil {
+		data.keyEqual, data.keyHash = newMapKeyFns(t)
+	}
+	return data.keyEqual, data.keyHash
+}
+
+func newMapKeyFns(t Type) (equalFn, hashFn) {
+	if _, ok := t.Def().(CanCompare); ok {
+		return compareEqual, debugHash
+	}
+	return nativeEqual, nativeHash
+}
+
+func compareEqual(a, b Value) bool {
+	return a.Compare(b) == 0
+}
+
+func debugHash(v Value) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(v.Debug()))
+	return h.Sum64()
+}
+
+func nativeEqual(a, b Value) bool {
+	return a == b
+}
+
+func nativeHash(v Value) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(v.typ.Hash()))
+	fmt.Fprintf(h, "%v", v.val)
+	return h.Sum64()
+}
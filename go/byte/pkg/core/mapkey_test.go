@@ -0,0 +1,114 @@
+package core_test
+
+import (
+	"testing"
+
+	"axlab.dev/byte/pkg/core"
+)
+
+func TestAsMapKeyNativeScalar(t *testing.T) {
+	types := core.TypeMap{}
+	equal, hash := types.AsMapKey(types.Int())
+
+	a := types.NewInt(42)
+	b := types.NewInt(42)
+	c := types.NewInt(43)
+
+	if !equal(a, b) {
+		t.Fatal("expected equal ints to compare equal")
+	}
+	if equal(a, c) {
+		t.Fatal("expected different ints to compare unequal")
+	}
+	if hash(a) != hash(b) {
+		t.Fatal("expected equal ints to hash the same")
+	}
+}
+
+func TestAsMapKeyIsCachedOnType(t *testing.T) {
+	types := core.TypeMap{}
+	typ := types.Str()
+
+	equalA, hashA := types.AsMapKey(typ)
+	equalB, hashB := types.AsMapKey(typ)
+
+	a, b := core.NewValue(typ, "x"), core.NewValue(typ, "x")
+	if equalA(a, b) != equalB(a, b) || hashA(a) != hashB(a) {
+		t.Fatal("expected repeated AsMapKey calls to return equivalent functions")
+	}
+}
+
+// compareType is a fixture type implementing CanCompare, exercising
+// AsMapKey's interface-like dispatch path instead of the native one.
+type compareType struct{}
+
+func (compareType) Name() string { return "Compare" }
+func (compareType) Repr() string { return "Compare" }
+
+func (compareType) NewValue(typ core.Type, args ...any) (core.Type, any) {
+	return typ, args[0]
+}
+
+func (compareType) Compare(a, b core.Value) int {
+	x, y := a.Any().(int), b.Any().(int)
+	return x - y
+}
+
+func TestAsMapKeyInterfaceLikeDispatch(t *testing.T) {
+	types := core.TypeMap{}
+	typ := types.Intern(compareType{})
+	equal, hash := types.AsMapKey(typ)
+
+	a := core.NewValue(typ, 1)
+	b := core.NewValue(typ, 1)
+	c := core.NewValue(typ, 2)
+
+	if !equal(a, b) {
+		t.Fatal("expected equal values to compare equal via CanCompare")
+	}
+	if equal(a, c) {
+		t.Fatal("expected different values to compare unequal via CanCompare")
+	}
+	if hash(a) != hash(b) {
+		t.Fatal("expected equal values to hash the same via Debug fallback")
+	}
+}
+
+func benchMapKeyValues(types *core.TypeMap, n int) (typ core.Type, values []core.Value) {
+	typ = types.Int()
+	for i := 0; i < n; i++ {
+		values = append(values, types.NewInt(i))
+	}
+	return typ, values
+}
+
+func BenchmarkAsMapKeyNative(b *testing.B) {
+	types := &core.TypeMap{}
+	typ, values := benchMapKeyValues(types, 1000)
+	equal, hash := types.AsMapKey(typ)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		v := values[i%len(values)]
+		hash(v)
+		equal(v, v)
+	}
+}
+
+func BenchmarkAsMapKeyInterfaceLike(b *testing.B) {
+	types := &core.TypeMap{}
+	typ := types.Intern(compareType{})
+
+	var values []core.Value
+	for i := 0; i < 1000; i++ {
+		values = append(values, core.NewValue(typ, i))
+	}
+	equal, hash := types.AsMapKey(typ)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		v := values[i%len(values)]
+		hash(v)
+		equal(v, v)
+	}
+}
@@ -5,7 +5,7 @@ import "fmt"
 var _str = strType{}
 
 func (m *TypeMap) Str() Type {
-	return m.Get(_str)
+	return m.Intern(_str)
 }
 
 type strType struct{}
@@ -28,3 +28,8 @@ func (t strType) NewValue(typ Type, args ...any) (Type, any) {
 		return InitError("invalid arguments", typ, args)
 	}
 }
+
+func (t strType) DisplayValue(v Value) string {
+	s, _ := v.Any().(string)
+	return s
+}
@@ -1,5 +1,7 @@
 package core
 
+import "io"
+
 type CanCreate interface {
 	NewValue(typ Type, v ...any) (Type, any)
 }
@@ -15,3 +17,17 @@ type CanDebug interface {
 type CanCompare interface {
 	Compare(a, b Value) int
 }
+
+// CanEncode is implemented by a custom IsType whose values the codec
+// subsystem (see codec.go) doesn't already know how to write -- it's given
+// the Value directly rather than just the underlying `any` so it can reuse
+// Value.Any()/Value.Type() as needed.
+type CanEncode interface {
+	EncodeValue(v Value, w io.Writer) error
+}
+
+// CanDecode is the inverse of CanEncode: typ is the already-reconstructed
+// destination type, and the returned `any` becomes the new Value's payload.
+type CanDecode interface {
+	DecodeValue(typ Type, r io.Reader) (any, error)
+}
@@ -11,14 +11,26 @@ import (
 
 // Map for types and their definitions.
 type TypeMap struct {
-	typeMap    map[string]*typeData
+	typeMap    map[TypeHash]*typeData
 	typeMapRw  sync.RWMutex
 	typeByName map[string]*typeData
+	nextID     TypeId
 
 	typeKeysRw sync.RWMutex
 	typeKeys   map[typeKeyId]*typeKeyData
 }
 
+// TypeId is a small per-TypeMap integer assigned the first time a type is
+// interned, for callers that want a cheap comparable handle instead of the
+// full TypeHash (e.g. as a map key or switch tag). Ids are stable for the
+// lifetime of a TypeMap but are not comparable across different TypeMaps.
+type TypeId uint64
+
+// TypeHash is the stable structural hash of a Type's name and representation
+// (see TypeMap.doGet), unique across TypeMaps since it depends only on a
+// type's own content.
+type TypeHash string
+
 // Interface for types that can be used as a `Type` in a `TypeMap`.
 type IsType interface {
 	Name() string
@@ -50,7 +62,11 @@ func (t Type) IsZero() bool {
 	return t.data == nil
 }
 
-func (t Type) Hash() string {
+func (t Type) Id() TypeId {
+	return t.data.id
+}
+
+func (t Type) Hash() TypeHash {
 	return t.data.hash
 }
 
@@ -58,6 +74,11 @@ func (t Type) String() string {
 	return t.data.repr
 }
 
+// Less reports whether t sorts before b, per Compare.
+func (t Type) Less(b Type) bool {
+	return t.Compare(b) < 0
+}
+
 func (t Type) Compare(b Type) int {
 	if t == b {
 		return 0
@@ -144,7 +165,10 @@ func (m *TypeMap) Key(types ...Type) TypeKey {
 	return TypeKey{data}
 }
 
-func (m *TypeMap) Get(def IsType) Type {
+// Intern returns the Type for def, creating and registering it the first
+// time it's seen for this TypeMap. Types with the same Name and Repr are
+// deduplicated to the same Type, identified by its structural TypeHash.
+func (m *TypeMap) Intern(def IsType) Type {
 	out, init := m.doGet(def)
 	if init {
 		if impl, ok := def.(interface{ InitType(Type) }); ok {
@@ -154,6 +178,18 @@ func (m *TypeMap) Get(def IsType) Type {
 	return out
 }
 
+// ByName returns the Type registered under name, if any has been interned
+// yet -- used by the codec (codec.go) to resolve a custom type on a
+// destination TypeMap that never saw the original IsType instance.
+func (m *TypeMap) ByName(name string) (Type, bool) {
+	m.typeMapRw.RLock()
+	defer m.typeMapRw.RUnlock()
+	if typ, ok := m.typeByName[name]; ok {
+		return Type{typ}, true
+	}
+	return Type{}, false
+}
+
 func (m *TypeMap) doGet(def IsType) (out Type, init bool) {
 	name := def.Name()
 	repr := def.Repr()
@@ -163,17 +199,18 @@ func (m *TypeMap) doGet(def IsType) (out Type, init bool) {
 	hasher.Write([]byte(name))
 	hasher.Write([]byte(repr))
 
-	hash := fmt.Sprintf("%x", hasher.Sum(nil))
+	hash := TypeHash(fmt.Sprintf("%x", hasher.Sum(nil)))
 	m.typeMapRw.Lock()
 	defer m.typeMapRw.Unlock()
 
 	if typ, ok := m.typeMap[hash]; ok {
 		return Type{typ}, false
 	} else {
-		typ = &typeData{src: m, def: def, name: name, repr: repr, hash: hash}
+		m.nextID++
+		typ = &typeData{src: m, def: def, name: name, repr: repr, hash: hash, id: m.nextID}
 		typ.key = m.Key(Type{typ})
 		if m.typeMap == nil {
-			m.typeMap = make(map[string]*typeData)
+			m.typeMap = make(map[TypeHash]*typeData)
 		}
 		m.typeMap[hash] = typ
 		if name != "" {
@@ -202,7 +239,13 @@ type typeData struct {
 	def  IsType
 	name string
 	repr string
-	hash string
+	hash TypeHash
+	id   TypeId
+
+	// keyEqual and keyHash are installed by AsMapKey the first time this
+	// type is used as a map key.
+	keyEqual equalFn
+	keyHash  hashFn
 }
 
 const typeKeySlots = 10
@@ -237,5 +280,5 @@ func (t Tuple) Repr() string {
 }
 
 func (m *TypeMap) TupleOf(elems ...Type) Type {
-	return m.Get(Tuple{m.Key(elems...)})
+	return m.Intern(Tuple{m.Key(elems...)})
 }
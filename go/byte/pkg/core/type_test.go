@@ -80,3 +80,47 @@ func TestTypeKeys(t *testing.T) {
 	test.Equal(k1d.String(), "((i32, i32, i64, i64))")
 	test.Equal(kx1.String(), "(i32, i32, i64, i64, (i32, i32, i64, i64), (i32, i32, i64, i64))")
 }
+
+func TestTypeIdAndHash(t *testing.T) {
+	test := require.New(t)
+	types := core.TypeMap{}
+
+	zero := core.Type{}
+	test.True(zero.IsZero())
+
+	i32 := types.Int32()
+	i64 := types.Int64()
+	test.NotEmpty(i32.Hash())
+	test.NotZero(i32.Id())
+	test.NotEqual(i32.Id(), i64.Id())
+	test.Equal(i32.Id(), types.Int32().Id())
+	test.Equal(i32.Hash(), types.Int32().Hash())
+
+	test.True(i32.Less(i64))
+	test.False(i64.Less(i32))
+}
+
+func TestCompositeTypes(t *testing.T) {
+	test := require.New(t)
+	types := core.TypeMap{}
+
+	i32 := types.Int32()
+
+	arr := types.ArrayOf(i32, 4)
+	test.Equal("[4]i32", arr.String())
+	test.Equal(arr, types.ArrayOf(i32, 4))
+	test.NotEqual(arr, types.ArrayOf(i32, 5))
+
+	sli := types.SliceOf(i32)
+	test.Equal("[]i32", sli.String())
+	test.Equal(sli, types.SliceOf(i32))
+
+	ref := types.RefOf(i32)
+	test.Equal("&i32", ref.String())
+	test.Equal(ref, types.RefOf(i32))
+
+	str := types.StructOf(core.StructField{Name: "x", Type: i32}, core.StructField{Name: "y", Type: i32})
+	test.Equal("{x: i32, y: i32}", str.String())
+	test.Equal(str, types.StructOf(core.StructField{Name: "x", Type: i32}, core.StructField{Name: "y", Type: i32}))
+	test.NotEqual(str, types.StructOf(core.StructField{Name: "x", Type: i32}))
+}
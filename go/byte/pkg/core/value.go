@@ -53,6 +53,10 @@ func (v Value) String() string {
 }
 
 func (v Value) Debug() string {
+	if v.IsZero() {
+		return "(none)"
+	}
+
 	if impl, ok := v.typ.Def().(CanDebug); ok {
 		return fmt.Sprintf("<%s>(%s)", v.typ, impl.DebugValue(v))
 	} else {
@@ -0,0 +1,103 @@
+// Package debug provides a structured trace/debug subsystem for the compile
+// pipeline, modeled on the Go compiler's `base.Debug`: a set of named knobs
+// that hot paths check before emitting trace events, toggled from a single
+// `-d key=val,key2=val2` style spec string.
+package debug
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Flags holds the named debug/trace knobs for the compile pipeline. Each
+// knob is an int so callers can use higher values for more verbose tracing
+// (e.g. `Lexer>=2` logs every accepted token).
+type Flags struct {
+	Lexer    int
+	Preproc  int
+	Bindings int
+	Queue    int
+	Segments int
+	Modules  int
+	Trace    int
+	Timings  int
+
+	// Output is where trace events are written. A nil Output defaults to
+	// os.Stderr.
+	Output io.Writer
+}
+
+// Parse builds a Flags from a `-d`-style spec such as "lexer=2,bindings=1".
+// A bare `key` with no `=value` is equivalent to `key=1`. Unknown keys are
+// reported as an error.
+func Parse(spec string) (out Flags, err error) {
+	if spec == "" {
+		return out, nil
+	}
+
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		key, val := part, "1"
+		if idx := strings.IndexByte(part, '='); idx >= 0 {
+			key, val = part[:idx], part[idx+1:]
+		}
+
+		n, convErr := strconv.Atoi(val)
+		if convErr != nil {
+			return out, fmt.Errorf("debug: invalid value for `%s`: %s", key, val)
+		}
+
+		field, ok := out.field(key)
+		if !ok {
+			return out, fmt.Errorf("debug: unknown flag `%s`", key)
+		}
+		*field = n
+	}
+
+	return out, nil
+}
+
+func (f *Flags) field(key string) (*int, bool) {
+	switch strings.ToLower(key) {
+	case "lexer":
+		return &f.Lexer, true
+	case "preproc":
+		return &f.Preproc, true
+	case "bindings":
+		return &f.Bindings, true
+	case "queue":
+		return &f.Queue, true
+	case "segments":
+		return &f.Segments, true
+	case "modules":
+		return &f.Modules, true
+	case "trace":
+		return &f.Trace, true
+	case "timings":
+		return &f.Timings, true
+	}
+	return nil, false
+}
+
+// Writer returns the configured Output, defaulting to os.Stderr.
+func (f *Flags) Writer() io.Writer {
+	if f.Output != nil {
+		return f.Output
+	}
+	return os.Stderr
+}
+
+// Logf writes a structured trace line to Writer() when enabled is true.
+func (f *Flags) Logf(enabled bool, format string, args ...any) {
+	if f == nil || !enabled {
+		return
+	}
+	fmt.Fprintf(f.Writer(), format+"\n", args...)
+}
@@ -0,0 +1,51 @@
+package debug
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseEmpty(t *testing.T) {
+	test := require.New(t)
+
+	flags, err := Parse("")
+	test.NoError(err)
+	test.Equal(Flags{}, flags)
+}
+
+func TestParseKnobs(t *testing.T) {
+	test := require.New(t)
+
+	flags, err := Parse("lexer=2,bindings,queue=0")
+	test.NoError(err)
+	test.Equal(2, flags.Lexer)
+	test.Equal(1, flags.Bindings)
+	test.Equal(0, flags.Queue)
+}
+
+func TestParseUnknownFlag(t *testing.T) {
+	test := require.New(t)
+
+	_, err := Parse("bogus=1")
+	test.Error(err)
+}
+
+func TestParseInvalidValue(t *testing.T) {
+	test := require.New(t)
+
+	_, err := Parse("lexer=nope")
+	test.Error(err)
+}
+
+func TestLogfWritesToOutput(t *testing.T) {
+	test := require.New(t)
+
+	out := &strings.Builder{}
+	flags := Flags{Output: out}
+	flags.Logf(true, "hello %s", "world")
+	flags.Logf(false, "should not appear")
+
+	test.Equal("hello world\n", out.String())
+}
@@ -0,0 +1,85 @@
+package lexer
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBlockCommentNonNested(t *testing.T) {
+	test := require.New(t)
+
+	lex := New()
+	lex.BlockComment("/*", "*/", false)
+
+	src := &Source{Name: "test", Text: "/* a /* b */ c"}
+	toks := lex.Tokenize(src)
+
+	test.Len(toks, 2)
+	test.Equal(TokenComment, toks[0].Kind)
+	test.Equal("/* a /* b */", toks[0].Span.Text())
+	test.Equal(TokenWord, toks[1].Kind)
+	test.Equal("c", toks[1].Span.Text())
+}
+
+func TestBlockCommentNested(t *testing.T) {
+	test := require.New(t)
+
+	lex := New()
+	lex.BlockComment("/*", "*/", true)
+
+	src := &Source{Name: "test", Text: "/* a /* b */ c */"}
+	toks := lex.Tokenize(src)
+
+	test.Len(toks, 1)
+	test.Equal(TokenComment, toks[0].Kind)
+	test.Equal("/* a /* b */ c */", toks[0].Span.Text())
+}
+
+func TestBlockCommentUnterminated(t *testing.T) {
+	test := require.New(t)
+
+	lex := New()
+	lex.BlockComment("/*", "*/", false)
+
+	src := &Source{Name: "test", Text: "/* never closed"}
+	toks := lex.Tokenize(src)
+
+	test.Len(toks, 1)
+	test.Equal(TokenInvalid, toks[0].Kind)
+	test.Equal("/* never closed", toks[0].Span.Text())
+
+	test.Len(lex.Errors, 1)
+	test.Contains(lex.Errors[0].String(), "unterminated block comment")
+}
+
+func TestMultipleCommentPrefixes(t *testing.T) {
+	test := require.New(t)
+
+	lex := New()
+	lex.Comments = []string{"//", "#"}
+	lex.AddSymbols("+")
+
+	src := &Source{Name: "test", Text: "a // one\nb # two\nc + d\n"}
+	toks := lex.Tokenize(src)
+
+	var kinds []TokenKind
+	var texts []string
+	for _, tok := range toks {
+		if tok.Kind != TokenBreak {
+			kinds = append(kinds, tok.Kind)
+			texts = append(texts, tok.Span.Text())
+		}
+	}
+
+	test.Equal([]TokenKind{
+		TokenWord, TokenComment,
+		TokenWord, TokenComment,
+		TokenWord, TokenSymbol, TokenWord,
+	}, kinds)
+	test.Equal([]string{
+		"a", "// one",
+		"b", "# two",
+		"c", "+", "d",
+	}, texts)
+}
@@ -0,0 +1,53 @@
+package lexer
+
+import "strings"
+
+// Edit describes a text replacement applied to a Source by ApplyEdit: the
+// bytes in [Sta, OldEnd) are replaced by whatever produced [Sta, NewEnd).
+// RowDelta is how many line breaks the edit added, negative if it removed
+// some -- together with Delta, it's enough to keep an existing Span in sync
+// with the new text without re-lexing it, see Apply.
+type Edit struct {
+	Sta, OldEnd, NewEnd int
+	RowDelta            int
+}
+
+// Delta is the byte length change the edit introduces, positive if the new
+// text is longer than what it replaced.
+func (e Edit) Delta() int {
+	return e.NewEnd - e.OldEnd
+}
+
+// ApplyEdit replaces the bytes of src.Text in [offset, offset+oldLen) with
+// newText and returns the resulting Edit. Any Span taken over src.Text
+// before this call must be reconciled against the Edit -- see Edit.Apply --
+// since its Sta/End may now point at the wrong bytes.
+func (src *Source) ApplyEdit(offset, oldLen int, newText string) Edit {
+	end := offset + oldLen
+	old := src.Text[offset:end]
+	src.Text = src.Text[:offset] + newText + src.Text[end:]
+
+	rowDelta := strings.Count(newText, "\n") - strings.Count(old, "\n")
+	return Edit{Sta: offset, OldEnd: end, NewEnd: offset + len(newText), RowDelta: rowDelta}
+}
+
+// Apply reconciles span against e:
+//
+//   - a span entirely before the edit (span.End <= e.Sta) is untouched;
+//   - a span entirely at or after it (span.Sta >= e.OldEnd) has its
+//     Sta/End/Row shifted by the edit's byte/line delta;
+//   - a span that straddles the edit no longer means the same bytes and is
+//     invalidated (ok is false).
+func (e Edit) Apply(span Span) (out Span, ok bool) {
+	switch {
+	case span.End <= e.Sta:
+		return span, true
+	case span.Sta >= e.OldEnd:
+		span.Sta += e.Delta()
+		span.End += e.Delta()
+		span.Row += e.RowDelta
+		return span, true
+	default:
+		return span, false
+	}
+}
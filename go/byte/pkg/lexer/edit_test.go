@@ -0,0 +1,66 @@
+package lexer
+
+import "testing"
+
+func TestApplyEdit(t *testing.T) {
+	src := &Source{Name: "test", Text: "let x = 1\nlet y = 2\n"}
+
+	edit := src.ApplyEdit(4, 1, "xyz")
+	if src.Text != "let xyz = 1\nlet y = 2\n" {
+		t.Fatalf("unexpected text after edit: %q", src.Text)
+	}
+	if edit.Sta != 4 || edit.OldEnd != 5 || edit.NewEnd != 7 {
+		t.Fatalf("unexpected edit bounds: %+v", edit)
+	}
+	if edit.Delta() != 2 {
+		t.Fatalf("expected delta 2, got %d", edit.Delta())
+	}
+	if edit.RowDelta != 0 {
+		t.Fatalf("expected no row delta, got %d", edit.RowDelta)
+	}
+}
+
+func TestApplyEditTracksRowDelta(t *testing.T) {
+	src := &Source{Name: "test", Text: "one\ntwo\nthree\n"}
+
+	edit := src.ApplyEdit(4, 3, "a\nb\nc")
+	if src.Text != "one\na\nb\nc\nthree\n" {
+		t.Fatalf("unexpected text after edit: %q", src.Text)
+	}
+	if edit.RowDelta != 2 {
+		t.Fatalf("expected row delta 2, got %d", edit.RowDelta)
+	}
+}
+
+func TestEditApplyUnaffectedBeforeEdit(t *testing.T) {
+	edit := Edit{Sta: 10, OldEnd: 15, NewEnd: 12, RowDelta: -1}
+	span := Span{Sta: 0, End: 5, Row: 1}
+
+	out, ok := edit.Apply(span)
+	if !ok || out != span {
+		t.Fatalf("expected span before the edit to be untouched, got %+v ok=%v", out, ok)
+	}
+}
+
+func TestEditApplyShiftsSpanAfterEdit(t *testing.T) {
+	edit := Edit{Sta: 10, OldEnd: 15, NewEnd: 12, RowDelta: -1}
+	span := Span{Sta: 20, End: 25, Row: 3}
+
+	out, ok := edit.Apply(span)
+	if !ok {
+		t.Fatal("expected span after the edit to stay valid")
+	}
+	if out.Sta != 17 || out.End != 22 || out.Row != 2 {
+		t.Fatalf("unexpected shifted span: %+v", out)
+	}
+}
+
+func TestEditApplyInvalidatesStraddlingSpan(t *testing.T) {
+	edit := Edit{Sta: 10, OldEnd: 15, NewEnd: 12}
+	span := Span{Sta: 8, End: 12}
+
+	_, ok := edit.Apply(span)
+	if ok {
+		t.Fatal("expected a span straddling the edit to be invalidated")
+	}
+}
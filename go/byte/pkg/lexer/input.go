@@ -15,6 +15,22 @@ type Source struct {
 	Text string
 	TabW int
 	Sort int // user defined global ordering for sources
+
+	// close releases whatever backs Text, if anything does -- e.g. the mmap
+	// OpenSourceMmap uses. nil for a Source built from a plain string, in
+	// which case Close is a no-op.
+	close func() error
+}
+
+// Close releases the resources backing src.Text, if it owns any. Using
+// src.Text or any Span over it after Close reads freed memory.
+func (src *Source) Close() error {
+	if src.close == nil {
+		return nil
+	}
+	err := src.close()
+	src.close = nil
+	return err
 }
 
 func (src *Source) TabWidth() int {
@@ -35,6 +51,12 @@ type Span struct {
 	Row int
 	Col int
 	Ind int
+
+	// ExpandedFrom, when non-nil, is the span this one was produced from --
+	// e.g. the macro invocation site a preprocessor expanded this span out
+	// of. Location walks the chain so an error inside a macro body still
+	// points back through to where it was expanded.
+	ExpandedFrom *Span
 }
 
 func (src *Source) Span() Span {
@@ -61,6 +83,9 @@ func (span Span) Location() string {
 	if len := span.Len(); len > 0 {
 		out += fmt.Sprintf("+%d", len)
 	}
+	if span.ExpandedFrom != nil {
+		out += fmt.Sprintf(" (expanded from %s)", span.ExpandedFrom.Location())
+	}
 	return out
 }
 
@@ -102,26 +127,33 @@ func (span *Span) Advance(size int) {
 	wasCr := false
 	for _, chr := range span.Text()[:size] {
 		span.Sta += utf8.RuneLen(chr)
-		if IsLineBreak(chr) {
-			if chr == '\n' && wasCr {
-				wasCr = false
-				continue
-			}
-			wasCr = chr == '\r'
-			span.Row += 1
-			span.Col = 1
-			span.Ind = 1
+		advancePos(&span.Row, &span.Col, &span.Ind, &wasCr, chr, tab)
+	}
+}
+
+// advancePos updates row/col/ind (and the carry-return flag wasCr) for a
+// single rune, as if it had just been consumed -- shared between Span and
+// translate's physical-position bookkeeping so the two can't drift apart.
+func advancePos(row, col, ind *int, wasCr *bool, chr rune, tabWidth int) {
+	if IsLineBreak(chr) {
+		if chr == '\n' && *wasCr {
+			*wasCr = false
+			return
+		}
+		*wasCr = chr == '\r'
+		*row += 1
+		*col = 1
+		*ind = 1
+	} else {
+		*wasCr = false
+		indent := *col == *ind
+		if chr == '\t' {
+			*col += tabWidth - (*col-1)%tabWidth
 		} else {
-			wasCr = false
-			indent := span.Col == span.Ind
-			if chr == '\t' {
-				span.Col += tab - (span.Col-1)%tab
-			} else {
-				span.Col += 1
-			}
-			if indent {
-				span.Ind = span.Col
-			}
+			*col += 1
+		}
+		if indent {
+			*ind = *col
 		}
 	}
 }
@@ -130,7 +162,7 @@ type typeOfSource struct{}
 type typeOfSourceKey struct{}
 
 func (src *Source) AsValue(typ *core.TypeMap) core.Value {
-	t := typ.Get(typeOfSource{})
+	t := typ.Intern(typeOfSource{})
 	return core.NewValue(t, src)
 }
 
@@ -156,7 +188,7 @@ func (t typeOfSource) DisplayValue(v core.Value) string {
 }
 
 func SourceKey(t *core.TypeMap) core.Value {
-	typ := t.Get(typeOfSourceKey{})
+	typ := t.Intern(typeOfSourceKey{})
 	return core.NewValue(typ)
 }
 
@@ -1,41 +1,230 @@
 package lexer
 
 import (
+	"fmt"
 	"regexp"
 	"sort"
 	"strings"
 	"unicode"
 	"unicode/utf8"
+
+	"axlab.dev/byte/pkg/debug"
 )
 
+// matcher pairs a registered matcher function with the set of first bytes a
+// span can start with for it to possibly match. wildcard marks a matcher
+// with no known prefix, which is tried for every byte (the wildcard bucket);
+// a matcher with an empty prefix set and wildcard unset is never tried.
+type matcher struct {
+	fn       func(span *Span) (bool, Token)
+	prefixes [256]bool
+	wildcard bool
+}
+
+// Preprocessor is an opt-in hook that Tokenize runs the raw token stream
+// through before returning it. It lets a caller layer C-style directive
+// handling (#define, #if, #include, ...) on top of a Lexer without this
+// package depending on that machinery -- see pkg/preproc for the built-in
+// implementation.
+type Preprocessor interface {
+	Process(src *Source, toks []Token) []Token
+}
+
+// Error is a diagnostic raised while tokenizing, collected on Lexer.Errors.
+type Error struct {
+	Msg string
+	At  Span
+}
+
+func (e Error) String() string {
+	if e.At.IsZero() {
+		return e.Msg
+	}
+	return fmt.Sprintf("%s: %s", e.At.Location(), e.Msg)
+}
+
 type Lexer struct {
-	Comment   string
-	symbol_re *regexp.Regexp
-	symbols   []string
-	matchers  []func(span *Span) (bool, Token)
+	// Comments holds the recognized line-comment prefixes (e.g. "//", "#"),
+	// tried in order -- so if one prefix is itself a prefix of another (like
+	// "#" and "#!"), register the more specific one first.
+	Comments     []string
+	Preprocessor Preprocessor
+
+	// Errors collects diagnostics raised while scanning -- currently just
+	// unterminated block comments from BlockComment. Tokenize never clears
+	// this, so a caller reusing a Lexer across sources should check and
+	// reset it between runs.
+	Errors []Error
+
+	// EnableTrigraphs and SpliceLines gate the C-style translation phases
+	// Tokenize runs before scanning: trigraph substitution and
+	// backslash-newline line splicing (plus, whenever either is on, a
+	// leading BOM strip). Both default to off, so a Lexer for a non-C-like
+	// language is unaffected -- see translate.go.
+	EnableTrigraphs bool
+	SpliceLines     bool
+
+	symbol_re    *regexp.Regexp
+	symbols      []string
+	symbolBytes  [256]bool
+	matchers     []matcher
+	index        [256][]matcher
+	indexDirty   bool
+	lineEnd      bool
+	lineEndKinds map[TokenKind]bool
+	indent       bool
+	dbg          *debug.Flags
 }
 
-func New() *Lexer {
-	return &Lexer{}
+// New creates a Lexer and applies each given Preset to it in order -- see
+// preset.go.
+func New(presets ...Preset) *Lexer {
+	lex := &Lexer{}
+	for _, preset := range presets {
+		lex.Apply(preset)
+	}
+	return lex
+}
+
+// SetDebug installs the debug flags checked by token tracing: once set,
+// every token Tokenize accepts is logged when Lexer>=2.
+func (lex *Lexer) SetDebug(dbg *debug.Flags) {
+	lex.dbg = dbg
 }
 
 func (lex *Lexer) Clone() *Lexer {
 	out := &Lexer{
-		Comment:   lex.Comment,
-		symbol_re: lex.symbol_re,
+		Preprocessor:    lex.Preprocessor,
+		EnableTrigraphs: lex.EnableTrigraphs,
+		SpliceLines:     lex.SpliceLines,
+		symbol_re:       lex.symbol_re,
+		symbolBytes:     lex.symbolBytes,
+		lineEnd:         lex.lineEnd,
+		indent:          lex.indent,
+		indexDirty:      true,
+		dbg:             lex.dbg,
 	}
+	out.Comments = append(out.Comments, lex.Comments...)
 	out.symbols = append(out.symbols, lex.symbols...)
 	out.matchers = append(out.matchers, lex.matchers...)
+	if lex.lineEndKinds != nil {
+		out.lineEndKinds = make(map[TokenKind]bool, len(lex.lineEndKinds))
+		for k, v := range lex.lineEndKinds {
+			out.lineEndKinds[k] = v
+		}
+	}
 	return out
 }
 
+// addMatcher registers fn and records it as dirtying the prefix index, so
+// the next lookup rebuilds the per-byte dispatch table.
+func (lex *Lexer) addMatcher(m matcher) {
+	lex.matchers = append(lex.matchers, m)
+	lex.indexDirty = true
+}
+
+// matchersFor returns, in registration order, every matcher that could
+// possibly match a span whose next byte is `b` -- i.e. matchers registered
+// with `b` as a possible prefix plus every wildcard matcher. The table is
+// built lazily and cached until a new matcher or symbol set invalidates it.
+func (lex *Lexer) matchersFor(b byte) []matcher {
+	if lex.indexDirty {
+		lex.rebuildIndex()
+	}
+	return lex.index[b]
+}
+
+func (lex *Lexer) rebuildIndex() {
+	for b := 0; b < 256; b++ {
+		bucket := lex.index[b][:0]
+		for _, m := range lex.matchers {
+			if m.wildcard || m.prefixes[b] {
+				bucket = append(bucket, m)
+			}
+		}
+		lex.index[b] = bucket
+	}
+	lex.indexDirty = false
+}
+
+// EnableLineTerminator turns on automatic statement terminator insertion:
+// after scanning a physical line, if the last non-comment token on that line
+// has one of the given kinds or is a closing bracket, Tokenize synthesizes a
+// zero-length TokenLineEnd at the line break. Continuation inside brackets
+// `()[]{}` suppresses the insertion. Mirrors what Go's lexer does to let
+// parsers drop explicit statement separators.
+func (lex *Lexer) EnableLineTerminator(kinds ...TokenKind) {
+	lex.lineEnd = true
+	lex.lineEndKinds = make(map[TokenKind]bool, len(kinds))
+	for _, k := range kinds {
+		lex.lineEndKinds[k] = true
+	}
+}
+
+// EnableIndentation turns on TokenIndent/TokenDedent synthesis: at the start
+// of each logical line, Tokenize compares the column of the first token
+// against the enclosing indentation levels and emits the markers needed to
+// widen or unwind the stack, Python-style -- see indentTracker.
+func (lex *Lexer) EnableIndentation() {
+	lex.indent = true
+}
+
+// BlockComment registers a matcher for comments delimited by open and close,
+// consuming up to and including the matching close. When nested is true, an
+// open encountered inside the comment increases a depth counter instead of
+// ending it, so e.g. `/* a /* b */ c */` reads as a single token (useful for
+// Rust/Swift/OCaml-style sources); otherwise the first close ends it, however
+// deep it looks nested. If close never appears before EOF, the token comes
+// back as TokenInvalid spanning to the end of input, and a diagnostic is
+// appended to lex.Errors.
+func (lex *Lexer) BlockComment(open, close string, nested bool) {
+	fn := func(span *Span) (ok bool, out Token) {
+		text := span.Text()
+		if !strings.HasPrefix(text, open) {
+			return
+		}
+
+		pos := len(open)
+		depth := 1
+		for depth > 0 {
+			rest := text[pos:]
+			atOpen := nested && strings.HasPrefix(rest, open)
+			atClose := strings.HasPrefix(rest, close)
+			switch {
+			case atClose:
+				pos += len(close)
+				depth--
+			case atOpen:
+				pos += len(open)
+				depth++
+			case pos >= len(text):
+				tokSpan := *span
+				tokSpan.End = tokSpan.Sta + len(text)
+				span.Advance(len(text))
+				lex.Errors = append(lex.Errors, Error{Msg: "unterminated block comment", At: tokSpan})
+				return true, Token{Kind: TokenInvalid, Span: tokSpan}
+			default:
+				_, size := utf8.DecodeRuneInString(rest)
+				pos += size
+			}
+		}
+
+		out = NewToken(TokenComment, span, pos)
+		return true, out
+	}
+
+	m := matcher{fn: fn}
+	m.prefixes[open[0]] = true
+	lex.addMatcher(m)
+}
+
 func (lex *Lexer) MatchNumbers() {
 	lex.MatchRE(TokenNumber, `0[xX][_A-Za-z0-9]*`)
 	lex.MatchRE(TokenNumber, `[0-9][_0-9]*(\.[0-9][_0-9]*)?([eE][-+]?[0-9][_0-9]*)?[_A-Za-z0-9]*`)
 }
 
 func (lex *Lexer) MatchQuotedString(quote string, double bool, escape string) {
-	lex.matchers = append(lex.matchers, func(span *Span) (ok bool, out Token) {
+	fn := func(span *Span) (ok bool, out Token) {
 		text := span.Text()
 		if strings.HasPrefix(text, quote) {
 			esc, pos := false, len(quote)
@@ -68,15 +257,25 @@ func (lex *Lexer) MatchQuotedString(quote string, double bool, escape string) {
 			return true, out
 		}
 		return
-	})
+	}
+
+	m := matcher{fn: fn}
+	m.prefixes[quote[0]] = true
+	lex.addMatcher(m)
 }
 
 func (lex *Lexer) MatchRE(kind TokenKind, re string) {
+	// LiteralPrefix must be computed from the unanchored source -- Go's
+	// regexp reports "" for an anchored, non-fully-literal pattern (e.g.
+	// `^0[xX]...`), which would silently put every MatchRE matcher in the
+	// wildcard bucket regardless of how selective its prefix actually is.
+	prefix, _ := regexp.MustCompile(re).LiteralPrefix()
+
 	if !strings.HasPrefix(re, "^") {
 		re = "^" + re
 	}
 	regex := regexp.MustCompile(re)
-	lex.matchers = append(lex.matchers, func(span *Span) (ok bool, out Token) {
+	fn := func(span *Span) (ok bool, out Token) {
 		text := span.Text()
 		size := len(regex.FindString(text))
 		if size > 0 {
@@ -84,7 +283,15 @@ func (lex *Lexer) MatchRE(kind TokenKind, re string) {
 			return true, out
 		}
 		return
-	})
+	}
+
+	m := matcher{fn: fn}
+	if prefix != "" {
+		m.prefixes[prefix[0]] = true
+	} else {
+		m.wildcard = true
+	}
+	lex.addMatcher(m)
 }
 
 func (lex *Lexer) AddSymbols(symbols ...string) {
@@ -95,22 +302,25 @@ func (lex *Lexer) AddSymbols(symbols ...string) {
 
 	re := strings.Builder{}
 	re.WriteString("^(")
+	lex.symbolBytes = [256]bool{}
 	for n, it := range lex.symbols {
 		if n > 0 {
 			re.WriteString("|")
 		}
 		re.WriteString(regexp.QuoteMeta(it))
+		lex.symbolBytes[it[0]] = true
 	}
 	re.WriteString(")")
 	lex.symbol_re = regexp.MustCompile(re.String())
+	lex.indexDirty = true
 }
 
 func (lex *Lexer) MatchSymbol(span *Span) (ok bool, out Token) {
-	if len(lex.symbols) == 0 {
+	text := span.Text()
+	if len(lex.symbols) == 0 || !lex.symbolBytes[text[0]] {
 		return
 	}
 
-	text := span.Text()
 	size := len(lex.symbol_re.FindString(text))
 	if size > 0 {
 		out = NewToken(TokenSymbol, span, size)
@@ -0,0 +1,38 @@
+package lexer
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLineTerminator(t *testing.T) {
+	test := require.New(t)
+
+	lex := New()
+	lex.AddSymbols("(", ")", "+")
+	lex.MatchNumbers()
+	lex.EnableLineTerminator(TokenWord, TokenNumber)
+
+	src := &Source{Name: "test", Text: "a\nb + (\n1\n)\n"}
+	kinds := func(text string) (out []TokenKind) {
+		src.Text = text
+		for _, tok := range lex.Tokenize(src) {
+			out = append(out, tok.Kind)
+		}
+		return out
+	}
+
+	test.Equal([]TokenKind{
+		TokenWord, TokenLineEnd, TokenBreak,
+		TokenWord,
+	}, kinds("a\nb"))
+
+	// continuation inside brackets suppresses the synthetic terminator,
+	// but a closing bracket at the end of a line still gets one
+	test.Equal([]TokenKind{
+		TokenSymbol, TokenBreak,
+		TokenNumber, TokenBreak,
+		TokenSymbol, TokenLineEnd, TokenBreak,
+	}, kinds("(\n1\n)\n"))
+}
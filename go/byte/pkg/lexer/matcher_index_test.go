@@ -0,0 +1,40 @@
+package lexer
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMatcherIndexDispatchesByPrefix(t *testing.T) {
+	test := require.New(t)
+
+	lex := New()
+	lex.MatchRE(TokenNumber, `0[xX][0-9a-fA-F]+`)
+	lex.MatchQuotedString(`"`, true, `\`)
+
+	hexBucket := lex.matchersFor('0')
+	test.Len(hexBucket, 1)
+
+	quoteBucket := lex.matchersFor('"')
+	test.Len(quoteBucket, 1)
+
+	// a byte with no registered prefix only sees the wildcard matchers
+	test.Empty(lex.matchersFor('x'))
+}
+
+func TestMatcherIndexRebuildsAfterAddSymbols(t *testing.T) {
+	test := require.New(t)
+
+	lex := New()
+	lex.AddSymbols("+", "++")
+
+	src := &Source{Name: "test", Text: "++"}
+	span := src.Span()
+	ok, tok := lex.MatchSymbol(&span)
+	test.True(ok)
+	test.Equal("++", tok.Span.Text())
+
+	lex.AddSymbols("-")
+	test.True(lex.symbolBytes['-'])
+}
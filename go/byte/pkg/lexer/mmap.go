@@ -0,0 +1,44 @@
+package lexer
+
+import (
+	"os"
+	"unsafe"
+
+	"github.com/edsrzf/mmap-go"
+)
+
+// OpenSourceMmap opens path and returns a Source whose Text is a zero-copy
+// view over the file's memory-mapped pages, rather than a full copy the way
+// os.ReadFile-backed sources are built. This avoids doubling memory for
+// large inputs (e.g. lexing a multi-megabyte vendored bundle) at the cost of
+// keeping the file mapped for as long as the Source is in use -- callers
+// must call Source.Close when they're done with it, and must not touch the
+// Source's Text or any Span over it afterward.
+func OpenSourceMmap(path string) (*Source, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	data, err := mmap.Map(f, mmap.RDONLY, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Source{
+		Name:  path,
+		Text:  unsafeString(data),
+		close: data.Unmap,
+	}, nil
+}
+
+// unsafeString views b as a string without copying it. The result is only
+// valid for as long as b's backing memory is -- here, as long as the mmap
+// that produced b stays mapped.
+func unsafeString(b []byte) string {
+	if len(b) == 0 {
+		return ""
+	}
+	return unsafe.String(&b[0], len(b))
+}
@@ -0,0 +1,77 @@
+package lexer
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestOpenSourceMmapReadsFileContents(t *testing.T) {
+	test := require.New(t)
+
+	path := filepath.Join(t.TempDir(), "source.txt")
+	test.NoError(os.WriteFile(path, []byte("hello world\n"), 0644))
+
+	src, err := OpenSourceMmap(path)
+	test.NoError(err)
+	test.Equal("hello world\n", src.Text)
+
+	test.NoError(src.Close())
+	test.NoError(src.Close()) // closing twice is a no-op, not an error
+}
+
+func benchSourcePath(b *testing.B) string {
+	b.Helper()
+
+	var body strings.Builder
+	for i := 0; i < 200_000; i++ {
+		body.WriteString("let x = 1 + 2 * (3 - four)\n")
+	}
+
+	path := filepath.Join(b.TempDir(), "large.txt")
+	if err := os.WriteFile(path, []byte(body.String()), 0644); err != nil {
+		b.Fatal(err)
+	}
+	return path
+}
+
+func benchLexer() *Lexer {
+	lex := New()
+	lex.Comments = []string{"//"}
+	lex.AddSymbols("+", "-", "*", "(", ")", "=")
+	lex.MatchNumbers()
+	return lex
+}
+
+func BenchmarkTokenizeReadFile(b *testing.B) {
+	path := benchSourcePath(b)
+	lex := benchLexer()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		text, err := os.ReadFile(path)
+		if err != nil {
+			b.Fatal(err)
+		}
+		src := &Source{Name: path, Text: string(text)}
+		lex.Tokenize(src)
+	}
+}
+
+func BenchmarkTokenizeMmap(b *testing.B) {
+	path := benchSourcePath(b)
+	lex := benchLexer()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		src, err := OpenSourceMmap(path)
+		if err != nil {
+			b.Fatal(err)
+		}
+		lex.Tokenize(src)
+		src.Close()
+	}
+}
@@ -0,0 +1,92 @@
+package lexer
+
+// Preset configures a Lexer for a particular source language. Presets are
+// applied via New or Apply and are plain functions so a caller can compose
+// its own on top of (or instead of) the built-in ones.
+type Preset func(lex *Lexer)
+
+// Apply runs preset against lex.
+func (lex *Lexer) Apply(preset Preset) {
+	preset(lex)
+}
+
+// PresetGo configures a Lexer for Go-like source: `//` line comments, `/*
+// */` block comments (Go's don't nest, matching the language spec), Go's
+// operator and bracket set, Go-style numeric literals (hex/octal/binary
+// with `_` separators and an imaginary `i` suffix), double-quoted and raw
+// (backtick) strings, and rune literals.
+var PresetGo Preset = func(lex *Lexer) {
+	lex.Comments = []string{"//"}
+	lex.BlockComment("/*", "*/", false)
+
+	lex.AddSymbols(
+		"+", "-", "*", "/", "%",
+		"&", "|", "^", "<<", ">>", "&^",
+		"&&", "||", "<-", "++", "--",
+		"==", "<", ">", "=", "!",
+		"!=", "<=", ">=", ":=",
+		"+=", "-=", "*=", "/=", "%=",
+		"&=", "|=", "^=", "<<=", ">>=", "&^=",
+		"~", "(", "[", "{", ")", "]", "}",
+		",", ";", ".", "...", ":",
+	)
+
+	lex.MatchRE(TokenNumber, `0[xX][_0-9a-fA-F]+i?`)
+	lex.MatchRE(TokenNumber, `0[oO][_0-7]+i?`)
+	lex.MatchRE(TokenNumber, `0[bB][_01]+i?`)
+	lex.MatchRE(TokenNumber, `[0-9][_0-9]*(\.[0-9][_0-9]*)?([eE][-+]?[0-9][_0-9]*)?i?`)
+
+	lex.MatchQuotedString(`"`, true, `\`)
+	lex.MatchQuotedString("`", false, "")
+	lex.MatchQuotedString(`'`, true, `\`)
+}
+
+// PresetC configures a Lexer for C-like source: trigraphs and backslash-
+// newline splicing, `//` and `/* */` comments, C's operator and bracket set,
+// and double- and single-quoted literals.
+var PresetC Preset = func(lex *Lexer) {
+	lex.EnableTrigraphs = true
+	lex.SpliceLines = true
+
+	lex.Comments = []string{"//"}
+	lex.BlockComment("/*", "*/", false)
+
+	lex.AddSymbols(
+		"+", "-", "*", "/", "%",
+		"&", "|", "^", "~", "<<", ">>",
+		"&&", "||", "!", "++", "--",
+		"==", "!=", "<", ">", "<=", ">=",
+		"=", "+=", "-=", "*=", "/=", "%=",
+		"&=", "|=", "^=", "<<=", ">>=",
+		"(", "[", "{", ")", "]", "}",
+		",", ";", ".", "->", "?", ":", "...",
+	)
+
+	lex.MatchNumbers()
+	lex.MatchQuotedString(`"`, true, `\`)
+	lex.MatchQuotedString(`'`, true, `\`)
+}
+
+// PresetPython configures a Lexer for Python-like source: `#` comments,
+// indentation-based TokenIndent/TokenDedent markers, Python's operator set,
+// and single-, double-, and triple-quoted strings.
+var PresetPython Preset = func(lex *Lexer) {
+	lex.Comments = []string{"#"}
+	lex.EnableIndentation()
+
+	lex.AddSymbols(
+		"+", "-", "*", "**", "/", "//", "%", "@",
+		"&", "|", "^", "~", "<<", ">>",
+		"<", ">", "<=", ">=", "==", "!=",
+		"=", "+=", "-=", "*=", "/=", "//=", "%=", "**=",
+		"&=", "|=", "^=", "<<=", ">>=", ":=",
+		"(", "[", "{", ")", "]", "}",
+		",", ":", ".", ";", "->",
+	)
+
+	lex.MatchNumbers()
+	lex.MatchQuotedString(`"""`, true, `\`)
+	lex.MatchQuotedString(`'''`, true, `\`)
+	lex.MatchQuotedString(`"`, true, `\`)
+	lex.MatchQuotedString(`'`, true, `\`)
+}
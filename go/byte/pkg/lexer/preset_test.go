@@ -0,0 +1,81 @@
+package lexer
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func tokenKinds(toks []Token) (out []TokenKind) {
+	for _, tok := range toks {
+		if tok.Kind != TokenBreak {
+			out = append(out, tok.Kind)
+		}
+	}
+	return out
+}
+
+func TestPresetGo(t *testing.T) {
+	test := require.New(t)
+
+	lex := New(PresetGo)
+	src := &Source{Name: "test", Text: "x := 0x1_0 + 1.5i // comment\n/* block */\ns := `raw` + \"str\"\n"}
+	toks := lex.Tokenize(src)
+
+	var texts []string
+	for _, tok := range toks {
+		if tok.Kind != TokenBreak {
+			texts = append(texts, tok.Span.Text())
+		}
+	}
+
+	test.Equal([]TokenKind{
+		TokenWord, TokenSymbol, TokenNumber, TokenSymbol, TokenNumber, TokenComment,
+		TokenComment,
+		TokenWord, TokenSymbol, TokenLiteral, TokenSymbol, TokenLiteral,
+	}, tokenKinds(toks))
+	test.Equal([]string{
+		"x", ":=", "0x1_0", "+", "1.5i", "// comment",
+		"/* block */",
+		"s", ":=", "`raw`", "+", `"str"`,
+	}, texts)
+}
+
+func TestPresetC(t *testing.T) {
+	test := require.New(t)
+
+	lex := New(PresetC)
+	src := &Source{Name: "test", Text: "int x = 1 ??< 2; /* block */\n"}
+	toks := lex.Tokenize(src)
+
+	var texts []string
+	for _, tok := range toks {
+		if tok.Kind != TokenBreak {
+			texts = append(texts, tok.Span.Text())
+		}
+	}
+
+	test.Equal([]TokenKind{
+		TokenWord, TokenWord, TokenSymbol, TokenNumber, TokenSymbol, TokenNumber, TokenSymbol, TokenComment,
+	}, tokenKinds(toks))
+	test.Equal([]string{
+		"int", "x", "=", "1", "??<", "2", ";", "/* block */",
+	}, texts)
+}
+
+func TestPresetPython(t *testing.T) {
+	test := require.New(t)
+
+	lex := New(PresetPython)
+	src := &Source{Name: "test", Text: "if x:\n    y = 1\n    z = 2\nw = 3\n"}
+	toks := lex.Tokenize(src)
+
+	test.Equal([]TokenKind{
+		TokenWord, TokenWord, TokenSymbol,
+		TokenIndent,
+		TokenWord, TokenSymbol, TokenNumber,
+		TokenWord, TokenSymbol, TokenNumber,
+		TokenDedent,
+		TokenWord, TokenSymbol, TokenNumber,
+	}, tokenKinds(toks))
+}
@@ -0,0 +1,81 @@
+package lexer
+
+import "math/rand"
+
+// SpanIndex is a treap over a set of Spans, keyed by Sta, for locating every
+// span overlapping an edited range in O(log n + k) instead of scanning
+// every span a caller is holding onto -- mirroring the segment treap
+// nodes.RangeTable indexes its bindings with. Spans added to a SpanIndex are
+// assumed non-overlapping, the same assumption RangeTable's segments make.
+//
+// A SpanIndex holds pointers into caller-owned storage (e.g. a Node's span
+// field) and never copies them, so mutating a returned Span through Edit.Apply
+// is visible to whoever added it.
+type SpanIndex struct {
+	tree *spanNode
+}
+
+type spanNode struct {
+	span     *Span
+	priority int64
+	left     *spanNode
+	right    *spanNode
+}
+
+// Add registers span for later lookup by Query.
+func (idx *SpanIndex) Add(span *Span) {
+	idx.tree = spanInsert(idx.tree, &spanNode{span: span, priority: rand.Int63()})
+}
+
+// Query returns every indexed span overlapping [sta, end), in Sta order.
+func (idx *SpanIndex) Query(sta, end int) (out []*Span) {
+	var walk func(*spanNode)
+	walk = func(n *spanNode) {
+		if n == nil {
+			return
+		}
+		if sta < n.span.Sta {
+			walk(n.left)
+		}
+		if n.span.Sta < end && n.span.End > sta {
+			out = append(out, n.span)
+		}
+		if end > n.span.Sta {
+			walk(n.right)
+		}
+	}
+	walk(idx.tree)
+	return out
+}
+
+func spanInsert(root, node *spanNode) *spanNode {
+	if root == nil {
+		return node
+	}
+	if node.span.Sta < root.span.Sta {
+		root.left = spanInsert(root.left, node)
+		if root.left.priority > root.priority {
+			root = spanRotateRight(root)
+		}
+	} else {
+		root.right = spanInsert(root.right, node)
+		if root.right.priority > root.priority {
+			root = spanRotateLeft(root)
+		}
+	}
+	return root
+}
+
+func spanRotateRight(root *spanNode) *spanNode {
+	pivot := root.left
+	root.left = pivot.right
+	pivot.right = root
+	return pivot
+}
+
+func spanRotateLeft(root *spanNode) *spanNode {
+	pivot := root.right
+	root.right = pivot.left
+	pivot.left = root
+	return pivot
+}
@@ -0,0 +1,52 @@
+package lexer
+
+import "testing"
+
+func TestSpanIndexQueryFindsOverlapping(t *testing.T) {
+	idx := &SpanIndex{}
+
+	a := &Span{Sta: 0, End: 5}
+	b := &Span{Sta: 5, End: 10}
+	c := &Span{Sta: 10, End: 15}
+	idx.Add(a)
+	idx.Add(b)
+	idx.Add(c)
+
+	out := idx.Query(4, 11)
+	if len(out) != 3 || out[0] != a || out[1] != b || out[2] != c {
+		t.Fatalf("unexpected query result: %+v", out)
+	}
+}
+
+func TestSpanIndexQueryExcludesNonOverlapping(t *testing.T) {
+	idx := &SpanIndex{}
+
+	a := &Span{Sta: 0, End: 5}
+	b := &Span{Sta: 20, End: 25}
+	idx.Add(a)
+	idx.Add(b)
+
+	out := idx.Query(6, 19)
+	if len(out) != 0 {
+		t.Fatalf("expected no spans in the gap, got %+v", out)
+	}
+}
+
+func TestSpanIndexTracksMutationThroughEdit(t *testing.T) {
+	idx := &SpanIndex{}
+
+	span := &Span{Sta: 20, End: 25, Row: 3}
+	idx.Add(span)
+
+	edit := Edit{Sta: 10, OldEnd: 15, NewEnd: 12, RowDelta: -1}
+	shifted, ok := edit.Apply(*span)
+	if !ok {
+		t.Fatal("expected span to remain valid")
+	}
+	*span = shifted
+
+	out := idx.Query(17, 22)
+	if len(out) != 1 || out[0].Sta != 17 {
+		t.Fatalf("expected the indexed span to reflect the mutation, got %+v", out)
+	}
+}
@@ -17,6 +17,16 @@ const (
 	TokenNumber  TokenKind = "Number"
 	TokenLiteral TokenKind = "Literal"
 	TokenComment TokenKind = "Comment"
+	// TokenLineEnd is a zero-length synthetic token emitted by Tokenize when
+	// EnableLineTerminator is active and a line break follows a
+	// terminator-eligible token outside of any bracket nesting.
+	TokenLineEnd TokenKind = "LineEnd"
+	// TokenIndent and TokenDedent are zero-length synthetic tokens emitted by
+	// Tokenize when EnableIndentation is active, marking where a logical
+	// line's leading whitespace widens or narrows relative to the enclosing
+	// block -- see indentTracker.
+	TokenIndent TokenKind = "Indent"
+	TokenDedent TokenKind = "Dedent"
 )
 
 type Token struct {
@@ -49,19 +59,139 @@ func (tok *Token) String() string {
 }
 
 func (lex *Lexer) Tokenize(src *Source) (out []Token) {
-	span := src.Span()
+	scanSrc := src
+	var runs []spliceRun
+	if lex.EnableTrigraphs || lex.SpliceLines {
+		logical, r := lex.translate(src)
+		scanSrc = &Source{Name: src.Name, Text: logical, TabW: src.TabW, Sort: src.Sort}
+		runs = r
+	}
+
+	span := scanSrc.Span()
+	depth := 0
+	eligible := false
+	indent := indentTracker{atLineStart: true, levels: []int{1}}
 	for !span.Empty() {
 		tok := lex.readNext(&span)
-		if tok.Kind != TokenNone {
-			out = append(out, tok)
+		if tok.Kind == TokenNone {
+			continue
 		}
+
+		lex.dbg.Logf(lex.dbg != nil && lex.dbg.Lexer >= 2, "[lexer] accepted %s", tok.String())
+
+		if lex.lineEnd {
+			switch tok.Kind {
+			case TokenSymbol:
+				delta := bracketDelta(tok.Span.Text())
+				depth += delta
+				eligible = delta < 0 || lex.lineEndKinds[tok.Kind]
+			case TokenBreak:
+				if eligible && depth <= 0 {
+					mark := tok.Span
+					mark.End = mark.Sta
+					out = append(out, Token{Kind: TokenLineEnd, Span: mark})
+				}
+				eligible = false
+			case TokenComment:
+				// comments don't affect terminator eligibility
+			default:
+				eligible = lex.lineEndKinds[tok.Kind]
+			}
+		}
+
+		if lex.indent {
+			out = append(out, indent.update(tok)...)
+		}
+
+		out = append(out, tok)
 		if tok.Kind == TokenInvalid {
 			break
 		}
 	}
+
+	if lex.indent {
+		out = append(out, indent.finish()...)
+	}
+
+	if lex.Preprocessor != nil {
+		out = lex.Preprocessor.Process(src, out)
+	}
+
+	if runs != nil {
+		for i := range out {
+			out[i].Span = remapSpan(out[i].Span, scanSrc, src, runs)
+		}
+	}
+
+	return out
+}
+
+// indentTracker turns a stream of tokens into TokenIndent/TokenDedent
+// markers by comparing each logical line's leading column against a stack
+// of enclosing indentation levels, Python-style. Blank and comment-only
+// lines don't count as a logical line and are skipped; it does not account
+// for bracket nesting, so a continuation line inside unclosed brackets is
+// still treated as its own logical line.
+type indentTracker struct {
+	atLineStart bool
+	levels      []int
+}
+
+// update inspects tok and returns the TokenIndent/TokenDedent markers (if
+// any) that belong immediately before it in the output stream.
+func (it *indentTracker) update(tok Token) (out []Token) {
+	switch tok.Kind {
+	case TokenBreak:
+		it.atLineStart = true
+		return nil
+	case TokenComment:
+		return nil
+	}
+
+	if !it.atLineStart {
+		return nil
+	}
+	it.atLineStart = false
+
+	col := tok.Span.Col
+	mark := tok.Span
+	mark.End = mark.Sta
+
+	for len(it.levels) > 1 && col < it.levels[len(it.levels)-1] {
+		it.levels = it.levels[:len(it.levels)-1]
+		out = append(out, Token{Kind: TokenDedent, Span: mark})
+	}
+	if col > it.levels[len(it.levels)-1] {
+		it.levels = append(it.levels, col)
+		out = append(out, Token{Kind: TokenIndent, Span: mark})
+	}
 	return out
 }
 
+// finish returns the trailing TokenDedent markers needed to unwind every
+// indentation level still open at end of input.
+func (it *indentTracker) finish() (out []Token) {
+	for len(it.levels) > 1 {
+		it.levels = it.levels[:len(it.levels)-1]
+		out = append(out, Token{Kind: TokenDedent})
+	}
+	return out
+}
+
+// bracketDelta returns +1 for an opening bracket, -1 for a closing one, and
+// 0 for anything else, so Tokenize can track nesting depth while deciding
+// whether to synthesize a TokenLineEnd.
+func bracketDelta(text string) int {
+	switch text {
+	case "(", "[", "{":
+		return 1
+	case ")", "]", "}":
+		return -1
+	default:
+		return 0
+	}
+}
+
 func (lex *Lexer) readNext(span *Span) (out Token) {
 	span.SkipSpaces()
 	if span.Empty() {
@@ -87,14 +217,16 @@ func (lex *Lexer) readNext(span *Span) (out Token) {
 	}
 
 	text := span.Text()
-	if strings.HasPrefix(text, lex.Comment) {
-		return span.ReadToken(TokenComment, func(chr rune) bool {
-			return !IsLineBreak(chr)
-		})
+	for _, prefix := range lex.Comments {
+		if strings.HasPrefix(text, prefix) {
+			return span.ReadToken(TokenComment, func(chr rune) bool {
+				return !IsLineBreak(chr)
+			})
+		}
 	}
 
-	for _, m := range lex.matchers {
-		if ok, tok := m(span); ok {
+	for _, m := range lex.matchersFor(text[0]) {
+		if ok, tok := m.fn(span); ok {
 			return tok
 		}
 	}
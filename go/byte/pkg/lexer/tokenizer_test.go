@@ -11,7 +11,7 @@ import (
 func TestTokenizer(t *testing.T) {
 	tester.CheckInput(t, "testdata/tokenizer", func(input tester.Input) any {
 		var out []string
-		src := lexer.SourceString(input.Name(), input.Text())
+		src := &lexer.Source{Name: input.Name(), Text: input.Text()}
 		lex := newLexer()
 		for _, it := range lex.Tokenize(src) {
 			out = append(out, fmt.Sprintf("%s\n    %s", it.String(), it.Span.Location()))
@@ -22,7 +22,7 @@ func TestTokenizer(t *testing.T) {
 
 func newLexer() *lexer.Lexer {
 	lex := lexer.New()
-	lex.Comment = "#"
+	lex.Comments = []string{"#"}
 
 	lex.AddSymbols("(", ")", "[", "]", "{", "}")
 	lex.AddSymbols(",", ".", ";")
@@ -0,0 +1,195 @@
+package lexer
+
+import (
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// trigraphSubs maps each trigraph's third character to the byte it stands
+// in for, per the nine `??x` sequences ISO C defines.
+var trigraphSubs = map[byte]byte{
+	'=': '#', '(': '[', '/': '\\', ')': ']', '\'': '^',
+	'<': '{', '!': '|', '>': '}', '-': '~',
+}
+
+const utf8BOM = "\uFEFF"
+
+// spliceRun marks one boundary between the logical text Tokenize scans and
+// the physical Source.Text it was translated from, recording the row/col/ind
+// state at that point so a span can be relocated without replaying the file
+// from the top. Consecutive runs bound a segment that is either a plain copy
+// (equal logical and physical length, byte-for-byte identical) or a
+// substitution (a trigraph or a spliced line break, where the lengths
+// differ) -- locate tells the two apart by comparing those lengths rather
+// than tagging each run, since a genuine copy segment can never end up with
+// mismatched lengths. The run list always ends with a sentinel at the full
+// length of the logical text, so every real run has a following one to pair
+// with.
+type spliceRun struct {
+	logicalSta    int
+	physicalSta   int
+	row, col, ind int
+}
+
+// translate applies the phases gated by lex.EnableTrigraphs and
+// lex.SpliceLines -- trigraph substitution and backslash-newline line
+// splicing, plus a leading BOM strip whenever either is enabled -- to
+// src.Text, producing the logical text Tokenize actually scans. src.Text
+// itself is never modified; the returned runs let remapSpan translate a
+// span over the logical text back onto src, so diagnostics still point at
+// the author's real position. Returns src.Text unchanged and nil runs if
+// neither phase is enabled.
+func (lex *Lexer) translate(src *Source) (logical string, runs []spliceRun) {
+	if !lex.EnableTrigraphs && !lex.SpliceLines {
+		return src.Text, nil
+	}
+
+	text := src.Text
+	physical := 0
+	if strings.HasPrefix(text, utf8BOM) {
+		physical = len(utf8BOM)
+		text = text[physical:]
+	}
+
+	tabWidth := src.TabWidth()
+	row, col, ind := 1, 1, 1
+	wasCr := false
+
+	var out strings.Builder
+	runs = append(runs, spliceRun{logicalSta: 0, physicalSta: physical, row: row, col: col, ind: ind})
+
+	consume := func(n int) {
+		for _, chr := range text[:n] {
+			advancePos(&row, &col, &ind, &wasCr, chr, tabWidth)
+		}
+		text, physical = text[n:], physical+n
+	}
+
+	for len(text) > 0 {
+		if lex.SpliceLines && text[0] == '\\' {
+			if skip := spliceLen(text[1:]); skip > 0 {
+				// the splice itself consumes physical bytes but contributes
+				// none to the logical text, so -- like a trigraph -- it needs
+				// its own run bracketing it off from the copy segment before it
+				runs = append(runs, spliceRun{logicalSta: out.Len(), physicalSta: physical, row: row, col: col, ind: ind})
+				consume(1 + skip)
+				runs = append(runs, spliceRun{logicalSta: out.Len(), physicalSta: physical, row: row, col: col, ind: ind})
+				continue
+			}
+		}
+
+		if lex.EnableTrigraphs && len(text) >= 3 && text[0] == '?' && text[1] == '?' {
+			if repl, ok := trigraphSubs[text[2]]; ok {
+				// the substituted byte maps 1:3 onto the trigraph it stands
+				// for, so it needs its own run: one that starts here, before
+				// consuming the three physical bytes, distinct from the
+				// run that resumes normal 1:1 copying after it
+				runs = append(runs, spliceRun{logicalSta: out.Len(), physicalSta: physical, row: row, col: col, ind: ind})
+				out.WriteByte(repl)
+				consume(3)
+				runs = append(runs, spliceRun{logicalSta: out.Len(), physicalSta: physical, row: row, col: col, ind: ind})
+				continue
+			}
+		}
+
+		_, size := utf8.DecodeRuneInString(text)
+		out.WriteString(text[:size])
+		consume(size)
+	}
+
+	runs = append(runs, spliceRun{logicalSta: out.Len(), physicalSta: physical, row: row, col: col, ind: ind})
+	return out.String(), runs
+}
+
+// spliceLen returns the byte length of a line break immediately following a
+// backslash ("\n", "\r\n" or "\r"), or 0 if rest doesn't start with one.
+func spliceLen(rest string) int {
+	switch {
+	case strings.HasPrefix(rest, "\r\n"):
+		return 2
+	case len(rest) > 0 && (rest[0] == '\n' || rest[0] == '\r'):
+		return 1
+	default:
+		return 0
+	}
+}
+
+// locate maps logicalOffset onto its physical offset and row/col/ind state.
+// biasEnd distinguishes the two ways an offset sitting exactly on a run
+// boundary can be read: as the start of the segment beginning there (Span.Sta)
+// or as the (exclusive) end of the segment ending there (Span.End) -- the two
+// disagree whenever that segment is a substitution, since a trigraph or a
+// spliced line break has no single physical offset that is simultaneously
+// "right before" and "right after" it.
+func locate(runs []spliceRun, physText string, tabWidth int, logicalOffset int, biasEnd bool) (physOffset, row, col, ind int) {
+	var i int
+	if biasEnd {
+		i = sort.Search(len(runs), func(i int) bool {
+			return runs[i].logicalSta >= logicalOffset
+		}) - 1
+	} else {
+		i = sort.Search(len(runs), func(i int) bool {
+			return runs[i].logicalSta > logicalOffset
+		}) - 1
+	}
+	if i < 0 {
+		i = 0
+	}
+	if i >= len(runs)-1 {
+		i = len(runs) - 2
+	}
+
+	run, next := runs[i], runs[i+1]
+	logicalLen := next.logicalSta - run.logicalSta
+	physicalLen := next.physicalSta - run.physicalSta
+
+	if logicalLen == physicalLen {
+		// a plain copy segment: identical bytes, so offsets inside it line
+		// up 1:1 and row/col can be replayed from the run's recorded state
+		physOffset = run.physicalSta + (logicalOffset - run.logicalSta)
+		row, col, ind = run.row, run.col, run.ind
+		wasCr := false
+		for _, chr := range physText[run.physicalSta:physOffset] {
+			advancePos(&row, &col, &ind, &wasCr, chr, tabWidth)
+		}
+		return
+	}
+
+	// a substitution segment has only one logical position worth of offsets
+	// to resolve (its start, and its end which is the next run's start) --
+	// which one depends on whether the caller wants the segment's near or
+	// far physical edge
+	if biasEnd {
+		return next.physicalSta, next.row, next.col, next.ind
+	}
+	return run.physicalSta, run.row, run.col, run.ind
+}
+
+// remapSpan relocates a span produced while scanning logicalSrc's text back
+// onto phys, the Source it was translated from, so Span.Text/Location
+// report the author's original bytes and position. A span whose Src isn't
+// logicalSrc -- e.g. a synthetic span a preprocessor built while pasting
+// tokens, or one from a nested #include -- is returned unchanged.
+func remapSpan(span Span, logicalSrc, phys *Source, runs []spliceRun) Span {
+	if span.Src != logicalSrc {
+		return span
+	}
+
+	tabWidth := phys.TabWidth()
+	physSta, row, col, ind := locate(runs, phys.Text, tabWidth, span.Sta, false)
+	physEnd := physSta
+	if span.End > span.Sta {
+		physEnd, _, _, _ = locate(runs, phys.Text, tabWidth, span.End, true)
+	}
+
+	out := span
+	out.Src = phys
+	out.Sta, out.End = physSta, physEnd
+	out.Row, out.Col, out.Ind = row, col, ind
+	if span.ExpandedFrom != nil {
+		mapped := remapSpan(*span.ExpandedFrom, logicalSrc, phys, runs)
+		out.ExpandedFrom = &mapped
+	}
+	return out
+}
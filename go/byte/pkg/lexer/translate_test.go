@@ -0,0 +1,105 @@
+package lexer
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTrigraphSubstitution(t *testing.T) {
+	test := require.New(t)
+
+	lex := New()
+	lex.Comments = []string{"//"}
+	lex.EnableTrigraphs = true
+	lex.AddSymbols("#", "[", "]")
+
+	src := &Source{Name: "test", Text: "??=include ??(x??)\n"}
+	var kinds []TokenKind
+	var texts []string
+	for _, tok := range lex.Tokenize(src) {
+		if tok.Kind != TokenBreak {
+			kinds = append(kinds, tok.Kind)
+			// Span.Text is remapped back onto the physical source, so it
+			// still shows the raw trigraph the author wrote, not the
+			// character it stands for
+			texts = append(texts, tok.Span.Text())
+		}
+	}
+	test.Equal([]TokenKind{TokenSymbol, TokenWord, TokenSymbol, TokenWord, TokenSymbol}, kinds)
+	test.Equal([]string{"??=", "include", "??(", "x", "??)"}, texts)
+}
+
+func TestTrigraphsOffByDefault(t *testing.T) {
+	test := require.New(t)
+
+	lex := New()
+	lex.Comments = []string{"//"}
+	lex.AddSymbols("??=")
+
+	src := &Source{Name: "test", Text: "??="}
+	toks := lex.Tokenize(src)
+	test.Len(toks, 1)
+	test.Equal("??=", toks[0].Span.Text())
+}
+
+func TestLineSplicing(t *testing.T) {
+	test := require.New(t)
+
+	lex := New()
+	lex.Comments = []string{"//"}
+	lex.SpliceLines = true
+
+	src := &Source{Name: "test", Text: "ab\\\ncd\n"}
+	toks := lex.Tokenize(src)
+	test.Len(toks, 2) // the joined word, then the trailing break
+
+	// the token's text is remapped back onto the physical source, so it
+	// still reports the spliced-over backslash-newline rather than the
+	// joined logical word the lexer actually scanned
+	test.Equal("ab\\\ncd", toks[0].Span.Text())
+}
+
+func TestTranslationPreservesOriginalLocation(t *testing.T) {
+	test := require.New(t)
+
+	lex := New()
+	lex.Comments = []string{"//"}
+	lex.EnableTrigraphs = true
+	lex.SpliceLines = true
+
+	src := &Source{Name: "test", Text: "one\\\n??=two\n"}
+	var words []Token
+	for _, tok := range lex.Tokenize(src) {
+		if tok.Kind == TokenWord {
+			words = append(words, tok)
+		}
+	}
+	test.Len(words, 1)
+
+	word := words[0]
+	test.Equal("one", word.Span.Text())
+	test.Same(src, word.Span.Src)
+	test.Equal(1, word.Span.Row)
+	test.Equal(1, word.Span.Col)
+
+	// the spliced-in second physical line still reports its own row, not
+	// the logical line the splice folded it into
+	sym := lex.Tokenize(src)[1]
+	test.Equal(TokenInvalid, sym.Kind)
+	test.Equal("??=", sym.Span.Text())
+	test.Equal(2, sym.Span.Row)
+	test.Equal(1, sym.Span.Col)
+}
+
+func TestLeadingBOMIsStripped(t *testing.T) {
+	test := require.New(t)
+
+	lex := New()
+	lex.SpliceLines = true
+
+	src := &Source{Name: "test", Text: "\uFEFFhello\n"}
+	toks := lex.Tokenize(src)
+	test.Equal("hello", toks[0].Span.Text())
+	test.Equal(1, toks[0].Span.Col)
+}
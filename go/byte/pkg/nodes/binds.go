@@ -5,8 +5,10 @@ import (
 	"fmt"
 	"sort"
 	"strings"
+	"sync"
 
 	"axlab.dev/byte/pkg/core"
+	"axlab.dev/byte/pkg/debug"
 	"axlab.dev/byte/pkg/lexer"
 )
 
@@ -39,19 +41,25 @@ func (seg *Segment) String() string {
 }
 
 type NodeSet struct {
+	mu       sync.Mutex
 	types    *core.TypeMap
 	bindings map[core.Value]*RangeTable
 	queue    *nodeSetQueue
+	produced map[*binding][]*Node
+	dbg      *debug.Flags
 }
 
-func newNodeSet(types *core.TypeMap, queue *nodeSetQueue) *NodeSet {
-	return &NodeSet{types: types, queue: queue}
+func newNodeSet(types *core.TypeMap, queue *nodeSetQueue, dbg *debug.Flags) *NodeSet {
+	return &NodeSet{types: types, queue: queue, dbg: dbg}
 }
 
 func (set *NodeSet) Types() *core.TypeMap {
 	return set.types
 }
 
+// Add is safe to call concurrently from multiple Operators: it only ever
+// grows or looks up a per-key RangeTable, each of which guards its own
+// mutations with its own lock.
 func (set *NodeSet) Add(node *Node) {
 	if key := node.Key(); !key.IsZero() {
 		tb := set.getTable(key)
@@ -59,6 +67,7 @@ func (set *NodeSet) Add(node *Node) {
 	}
 }
 
+// Bind is safe to call concurrently; see Add.
 func (set *NodeSet) Bind(span lexer.Span, key, ord core.Value, val any) {
 	if !key.IsZero() {
 		tb := set.getTable(key)
@@ -66,6 +75,107 @@ func (set *NodeSet) Bind(span lexer.Span, key, ord core.Value, val any) {
 	}
 }
 
+// lookupTable returns the RangeTable for key, if one has been created.
+func (set *NodeSet) lookupTable(key core.Value) (*RangeTable, bool) {
+	set.mu.Lock()
+	defer set.mu.Unlock()
+	tb, ok := set.bindings[key]
+	return tb, ok
+}
+
+// TrackOutput records that processing the segment bound to `bind` produced
+// `node` as an output. This builds the reverse map used by Invalidate to
+// unwind downstream outputs when their source range is reprocessed. Safe to
+// call concurrently from multiple Operators sharing this NodeSet.
+func (set *NodeSet) TrackOutput(bind *binding, node *Node) {
+	if bind == nil {
+		return
+	}
+	set.mu.Lock()
+	defer set.mu.Unlock()
+	if set.produced == nil {
+		set.produced = make(map[*binding][]*Node)
+	}
+	set.produced[bind] = append(set.produced[bind], node)
+}
+
+// unwind removes every Node that was produced while processing the segment
+// bound to `bind`, recursively unwinding anything those nodes produced in
+// turn.
+func (set *NodeSet) unwind(bind *binding) {
+	set.mu.Lock()
+	outs := set.produced[bind]
+	delete(set.produced, bind)
+	set.mu.Unlock()
+
+	for _, node := range outs {
+		if key := node.Key(); !key.IsZero() {
+			if tb, ok := set.lookupTable(key); ok {
+				tb.removeNode(node)
+			}
+		}
+	}
+}
+
+// Invalidate reprocesses every RangeTable tracked by this set for the given
+// span: it tombstones the bindings covering the span, unwinds the outputs
+// they produced, restores whatever they displaced, and requeues only the
+// disturbed segments.
+func (set *NodeSet) Invalidate(span lexer.Span) {
+	for _, tb := range set.tables() {
+		tb.Invalidate(set, span.Sta, span.End)
+	}
+}
+
+// ShiftFrom offsets every Node and binding at or past `pos` by `delta` bytes
+// and `rowDelta` lines. This is used after a source edit that changes the
+// text length, so spans past the edit keep pointing at the right bytes
+// (and the right line) without a full re-lex.
+func (set *NodeSet) ShiftFrom(pos, delta, rowDelta int) {
+	if delta == 0 && rowDelta == 0 {
+		return
+	}
+	for _, tb := range set.tables() {
+		tb.shiftFrom(pos, delta, rowDelta)
+	}
+}
+
+// tables returns a snapshot of every RangeTable currently tracked by this
+// set, so callers can iterate without holding set.mu (and without racing a
+// concurrent getTable call that grows the map).
+func (set *NodeSet) tables() []*RangeTable {
+	set.mu.Lock()
+	defer set.mu.Unlock()
+	out := make([]*RangeTable, 0, len(set.bindings))
+	for _, tb := range set.bindings {
+		out = append(out, tb)
+	}
+	return out
+}
+
+func (tb *RangeTable) shiftFrom(pos, delta, rowDelta int) {
+	for _, seg := range tb.segments() {
+		if seg.sta >= pos {
+			seg.sta += delta
+		}
+		if seg.end >= pos {
+			seg.end += delta
+		}
+		if seg.bind.sta >= pos {
+			seg.bind.sta += delta
+		}
+		if seg.bind.end >= pos {
+			seg.bind.end += delta
+		}
+		for _, node := range seg.list {
+			node.shiftOffset(pos, delta, rowDelta)
+		}
+	}
+	for _, node := range tb.unbound {
+		node.shiftOffset(pos, delta, rowDelta)
+	}
+}
+
 type unboundSort struct {
 	keys  []core.Value
 	nodes [][]*Node
@@ -85,11 +195,22 @@ func (s unboundSort) Swap(a, b int) {
 }
 
 func (set *NodeSet) PopUnbound() (keys []core.Value, nodes [][]*Node) {
+	set.mu.Lock()
+	tables := make(map[core.Value]*RangeTable, len(set.bindings))
 	for k, v := range set.bindings {
-		if len(v.unbound) > 0 {
+		tables[k] = v
+	}
+	set.mu.Unlock()
+
+	for k, tb := range tables {
+		tb.mu.Lock()
+		unbound := tb.unbound
+		tb.unbound = nil
+		tb.mu.Unlock()
+
+		if len(unbound) > 0 {
 			keys = append(keys, k)
-			nodes = append(nodes, v.unbound)
-			v.unbound = nil
+			nodes = append(nodes, unbound)
 		}
 	}
 
@@ -99,8 +220,16 @@ func (set *NodeSet) PopUnbound() (keys []core.Value, nodes [][]*Node) {
 	return
 }
 
+// nodeSetQueue is the shared priority queue draining across every
+// RangeTable in a Program. Its heap is mutated both by the sequential
+// Evaluate loop (Peek/Shift, with no concurrent access during that window)
+// and by segment.ensureQueued/updateQueuePos/removeQueuePos/requeue calls
+// made from addBinding while Operators run concurrently, so those call
+// sites take mu.
 type nodeSetQueue struct {
+	mu       sync.Mutex
 	segments []*segment
+	dbg      *debug.Flags
 }
 
 func (q *nodeSetQueue) Peek() Segment {
@@ -117,6 +246,8 @@ func (q *nodeSetQueue) Shift() Segment {
 	if q.Len() > 0 {
 		seg := q.segments[0]
 		out := newSegment(seg)
+		q.dbg.Logf(q.dbg != nil && q.dbg.Queue > 0, "[queue] shift key=%s ord=%s span=%d..%d",
+			seg.bind.key.Debug(), seg.bind.ord.Debug(), seg.sta, seg.end)
 		seg.list = nil
 		heap.Pop(q)
 		return out
@@ -164,6 +295,8 @@ func (q *nodeSetQueue) Push(x any) {
 	seg := x.(*segment)
 	seg.queue = q.Len()
 	q.segments = append(q.segments, seg)
+	q.dbg.Logf(q.dbg != nil && q.dbg.Queue >= 2, "[queue] push key=%s ord=%s span=%d..%d",
+		seg.bind.key.Debug(), seg.bind.ord.Debug(), seg.sta, seg.end)
 }
 
 func (q *nodeSetQueue) Pop() any {
@@ -181,6 +314,9 @@ func (q *nodeSetQueue) shiftEmpty() {
 }
 
 func (set *NodeSet) getTable(key core.Value) *RangeTable {
+	set.mu.Lock()
+	defer set.mu.Unlock()
+
 	if tb, ok := set.bindings[key]; ok {
 		return tb
 	}
@@ -189,28 +325,48 @@ func (set *NodeSet) getTable(key core.Value) *RangeTable {
 		set.bindings = make(map[core.Value]*RangeTable)
 	}
 
-	tb := &RangeTable{queue: set.queue}
+	tb := &RangeTable{queue: set.queue, dbg: set.dbg}
 	set.bindings[key] = tb
 	return tb
 }
 
+// RangeTable holds the bindings and nodes for a single key. Add, Bind and
+// Get take tb.mu, so Operators running on different RangeTables (or
+// different, non-overlapping ranges of the same one) can run concurrently.
+// Invalidate, shiftFrom and removeNode take no lock and must not run
+// concurrently with any other call on the same table.
+//
+// Segments are indexed by a treap (tree) keyed by sta, so Get is O(log n)
+// and Bind only has to split/merge the segments its span actually overlaps
+// (O(k log n) for k touched segments) rather than rebuild the whole table.
 type RangeTable struct {
-	queue    *nodeSetQueue
-	segments []*segment
-	unbound  []*Node
+	mu      sync.Mutex
+	queue   *nodeSetQueue
+	tree    *segNode
+	unbound []*Node
+	dbg     *debug.Flags
+}
+
+// segments returns every bound segment in ascending sta order, via an
+// in-order walk of tree. Callers that need the whole table -- tests, debug
+// dumps, shiftFrom -- pay the O(n) traversal; Bind and Invalidate never call
+// this themselves, so it doesn't turn a localized edit into a full-table
+// cost.
+func (tb *RangeTable) segments() []*segment {
+	return treapCollect(tb.tree)
 }
 
 func (tb *RangeTable) Get(pos int) any {
-	cnt := len(tb.segments)
-	idx := sort.Search(cnt, func(i int) bool {
-		return tb.segments[i].end > pos
-	})
-	if idx < cnt && pos >= tb.segments[idx].sta {
-		return tb.segments[idx].bind.val
+	tb.mu.Lock()
+	defer tb.mu.Unlock()
+
+	if seg := treapSearch(tb.tree, pos); seg != nil {
+		return seg.bind.val
 	}
 	return nil
 }
 
+// Bind is safe to call concurrently across Operators; see RangeTable.
 func (tb *RangeTable) Bind(span lexer.Span, key, ord core.Value, val any) {
 	sta, end := span.Sta, span.End
 	if sta >= end {
@@ -221,14 +377,13 @@ func (tb *RangeTable) Bind(span lexer.Span, key, ord core.Value, val any) {
 }
 
 func (tb *RangeTable) Add(node *Node) {
+	tb.mu.Lock()
+	defer tb.mu.Unlock()
+
 	pos := node.Offset()
-	cnt := len(tb.segments)
-	idx := sort.Search(cnt, func(i int) bool {
-		return tb.segments[i].end > pos
-	})
-	if idx < cnt && pos >= tb.segments[idx].sta {
-		insertNode(&tb.segments[idx].list, node)
-		tb.segments[idx].ensureQueued(tb.queue)
+	if seg := treapSearch(tb.tree, pos); seg != nil {
+		insertNode(&seg.list, node)
+		seg.ensureQueued(tb.queue)
 	} else {
 		insertNode(&tb.unbound, node)
 	}
@@ -276,86 +431,177 @@ type segment struct {
 	bind  *binding
 	list  []*Node
 	queue int
+
+	// tombstone marks a segment that has been superseded by a newer binding
+	// but is kept around (via prev) so Invalidate can restore it.
+	tombstone bool
+	prev      *segment
 }
 
 func (seg *segment) updateQueuePos(q *nodeSetQueue) {
+	if q == nil {
+		return
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
 	if seg.queue >= 0 {
 		heap.Fix(q, seg.queue)
 	}
 }
 
 func (seg *segment) ensureQueued(q *nodeSetQueue) {
+	if q == nil {
+		return
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
 	if seg.queue < 0 {
 		heap.Push(q, seg)
 	}
 }
 
 func (seg *segment) removeQueuePos(q *nodeSetQueue) {
+	if q == nil {
+		return
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
 	if seg.queue >= 0 {
 		heap.Remove(q, seg.queue)
 	}
 }
 
+// requeue makes sure seg is in the queue and, if it already was, that its
+// position reflects its current sta/end/bind. A segment that was already
+// shifted off the queue (e.g. processed by a worker earlier in this epoch)
+// and is then touched again by a concurrent Bind -- a write landing in an
+// already-processed range -- gets pushed back on rather than silently
+// dropped, since updateQueuePos alone is a no-op for an unqueued segment.
+func (seg *segment) requeue(q *nodeSetQueue) {
+	if q == nil {
+		return
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if seg.queue < 0 {
+		heap.Push(q, seg)
+	} else {
+		heap.Fix(q, seg.queue)
+	}
+}
+
 func (seg *segment) splitOff(at int) (new *segment) {
 	if at <= seg.sta || seg.end <= at {
 		panic("splitting a segment out of bounds")
 	}
 
 	lhs, rhs := splitNodes(seg.list, at)
-	new = &segment{at, seg.end, seg.bind, rhs, -1}
+	new = &segment{sta: at, end: seg.end, bind: seg.bind, list: rhs, queue: -1}
 	seg.end, seg.list = at, lhs
 	return new
 }
 
+// addBinding is safe to call concurrently from multiple Operators: it takes
+// tb.mu for its whole body, including the requeueing it does on tb.queue
+// (which itself has its own lock, taken by the segment helpers below).
+//
+// The table is split into preTree/midTree/posTree around [sta,end) via two
+// O(log n) treap splits, so only the k segments in midTree (the ones the new
+// binding actually overlaps) are walked and rebuilt -- preTree and posTree
+// are reattached whole by an O(log n) merge rather than copied. preTree's
+// last segment is pulled out and seeded into result so the merge check in
+// push can still fuse across the pre/mid boundary exactly as it would if
+// result were still a suffix of the old flat segments slice.
 func (tb *RangeTable) addBinding(new_bind *binding) {
-	sta, end := new_bind.sta, new_bind.end
-	pre, mid, pos := splitSegments(tb.segments, sta, end)
+	tb.mu.Lock()
+	defer tb.mu.Unlock()
 
-	tb.segments = append([]*segment(nil), pre...)
+	sta, end := new_bind.sta, new_bind.end
+	preTree, rest := treapSplitByEnd(tb.tree, sta)
+	midTree, posTree := treapSplitBySta(rest, end)
+	mid := treapCollect(midTree)
+
+	var result []*segment
+	if last := treapRightmost(preTree); last != nil {
+		preTree = treapRemoveRightmost(preTree)
+		result = append(result, last)
+	}
 
 	push := func(seg *segment, isNew bool) *segment {
 		if isNew {
 			seg.list = extractNodes(&tb.unbound, seg.sta, seg.end)
 		}
 
-		if len(tb.segments) > 0 {
-			last := tb.segments[len(tb.segments)-1]
+		if len(result) > 0 {
+			last := result[len(result)-1]
 			can_merge := last.bind == seg.bind && last.end == seg.sta
 			if can_merge {
+				tb.dbg.Logf(tb.dbg != nil && tb.dbg.Bindings > 0, "[bindings] merge key=%s ord=%s span=%d..%d + %d..%d",
+					last.bind.key.Debug(), last.bind.ord.Debug(), last.sta, last.end, seg.sta, seg.end)
 				last.end = seg.end
 				last.list = append(last.list, seg.list...)
 				seg.list = nil
 				seg.removeQueuePos(tb.queue)
+				// the merged run always traces back to new_bind, so if any
+				// piece of it came from a reassigned (overridden) segment
+				// the whole thing needs to be (re)visited.
+				last.requeue(tb.queue)
 				return last
 			}
 		}
 
-		tb.segments = append(tb.segments, seg)
-		seg.updateQueuePos(tb.queue)
+		result = append(result, seg)
+		if isNew {
+			// a brand new gap segment is only worth queueing once it
+			// actually has nodes to process; Add() queues it later.
+			seg.updateQueuePos(tb.queue)
+		} else {
+			// seg already existed under a different bind and is being
+			// reassigned here -- including the case where it was already
+			// shifted off the queue by an earlier epoch. Re-queue it
+			// unconditionally so the new owner actually gets to run.
+			seg.requeue(tb.queue)
+		}
 		return seg
 	}
 
 	cur := sta
 	for _, next := range mid {
 		if has_gap := next.sta > cur; has_gap {
-			push(&segment{cur, next.sta, new_bind, nil, -1}, true)
+			push(&segment{sta: cur, end: next.sta, bind: new_bind, queue: -1}, true)
 			cur = next.sta
 		}
 
 		if new_bind.overrides(next.bind) {
+			tb.dbg.Logf(tb.dbg != nil && tb.dbg.Bindings > 0, "[bindings] override key=%s ord=%s span=%d..%d displaces ord=%s",
+				new_bind.key.Debug(), new_bind.ord.Debug(), next.sta, next.end, next.bind.ord.Debug())
+
 			if split_pre := next.sta < cur; split_pre {
+				tb.dbg.Logf(tb.dbg != nil && tb.dbg.Segments > 0, "[segments] split key=%s span=%d..%d at=%d",
+					next.bind.key.Debug(), next.sta, next.end, cur)
 				next = push(next, false)
 				next = next.splitOff(cur)
 			}
 
+			// split_pos must happen *before* the prev snapshot below is
+			// built, so the snapshot's [sta,end) and list only cover the
+			// range new_bind actually displaces -- [cur,end) -- rather than
+			// next's full pre-split range, which would leave Invalidate
+			// restoring a too-wide, overlapping segment later.
+			var tail *segment
+			if split_pos := end < next.end; split_pos {
+				tb.dbg.Logf(tb.dbg != nil && tb.dbg.Segments > 0, "[segments] split key=%s span=%d..%d at=%d",
+					next.bind.key.Debug(), next.sta, next.end, end)
+				tail = next.splitOff(end)
+			}
+
 			prev_bind := next.bind
+			next.prev = &segment{sta: next.sta, end: next.end, bind: prev_bind, queue: -1, prev: next.prev}
 			next.bind = new_bind
 			next = push(next, false)
 
-			if split_pos := end < next.end; split_pos {
-				next = next.splitOff(end)
-				next.bind = prev_bind
-				push(next, false)
+			if tail != nil {
+				push(tail, false)
 			}
 		} else {
 			next = push(next, false)
@@ -364,25 +610,79 @@ func (tb *RangeTable) addBinding(new_bind *binding) {
 	}
 
 	if cur < end {
-		push(&segment{cur, end, new_bind, nil, -1}, true)
+		push(&segment{sta: cur, end: end, bind: new_bind, queue: -1}, true)
 	}
 
-	tb.segments = append(tb.segments, pos...)
-}
+	tb.tree = treapMerge(treapMerge(preTree, treapBuild(result)), posTree)
+}
+
+// Invalidate reprocesses the span [sta,end) of the table: every segment that
+// overlaps the span is tombstoned, its outputs are unwound via `set`, and
+// whatever binding it displaced (tracked by `prev`) is restored in its place.
+// Only the segments that actually changed are pushed back onto tb.queue.
+//
+// Like addBinding, this splits the tree around [sta,end) in O(log n) and
+// only walks the overlapping segments, reattaching the untouched pre/pos
+// trees whole.
+func (tb *RangeTable) Invalidate(set *NodeSet, sta, end int) {
+	preTree, rest := treapSplitByEnd(tb.tree, sta)
+	midTree, posTree := treapSplitBySta(rest, end)
+	mid := treapCollect(midTree)
+
+	var out []*segment
+	if last := treapRightmost(preTree); last != nil {
+		preTree = treapRemoveRightmost(preTree)
+		out = append(out, last)
+	}
 
-func splitSegments(segments []*segment, sta, end int) (pre, mid, pos []*segment) {
-	count := len(segments)
-	idx_sta := sort.Search(count, func(i int) bool {
-		return segments[i].end > sta
-	})
-	idx_end := idx_sta + sort.Search(count-idx_sta, func(i int) bool {
-		return segments[i+idx_sta].sta >= end
-	})
+	for _, seg := range mid {
+		seg.removeQueuePos(tb.queue)
+		seg.tombstone = true
+		if set != nil {
+			set.unwind(seg.bind)
+		}
 
-	pre = segments[:idx_sta]
-	mid = segments[idx_sta:idx_end]
-	pos = segments[idx_end:]
-	return
+		if restored := seg.prev; restored != nil {
+			// seg.list is the authoritative current content for
+			// [restored.sta,restored.end) -- the override never cleared or
+			// replaced it, only Add() grew it further in place -- so
+			// restoring just takes it over rather than appending onto a
+			// separate snapshot, which would double every node still there
+			// from before the override.
+			restored.list = seg.list
+			restored.queue = -1
+			restored.ensureQueued(tb.queue)
+
+			if last := len(out); last > 0 && out[last-1].bind == restored.bind && out[last-1].end == restored.sta {
+				out[last-1].end = restored.end
+				out[last-1].list = append(out[last-1].list, restored.list...)
+				restored.removeQueuePos(tb.queue)
+			} else {
+				out = append(out, restored)
+			}
+		} else {
+			tb.unbound = append(tb.unbound, seg.list...)
+			sort.Slice(tb.unbound, func(a, b int) bool {
+				return tb.unbound[a].Offset() < tb.unbound[b].Offset()
+			})
+		}
+	}
+
+	tb.tree = treapMerge(treapMerge(preTree, treapBuild(out)), posTree)
+}
+
+// removeNode deletes a single Node produced by a binding that is being
+// unwound. The node is located by its offset within the bound segments.
+func (tb *RangeTable) removeNode(node *Node) {
+	pos := node.Offset()
+	if seg := treapSearch(tb.tree, pos); seg != nil {
+		for i, it := range seg.list {
+			if it == node {
+				seg.list = append(seg.list[:i], seg.list[i+1:]...)
+				return
+			}
+		}
+	}
 }
 
 func extractNodes(nodes *[]*Node, sta, end int) (del []*Node) {
@@ -2,10 +2,13 @@ package nodes
 
 import (
 	"fmt"
+	"math/rand"
+	"sort"
 	"strings"
 	"testing"
 
 	"axlab.dev/byte/pkg/core"
+	"axlab.dev/byte/pkg/debug"
 	"axlab.dev/byte/pkg/lexer"
 	"github.com/stretchr/testify/require"
 )
@@ -35,7 +38,7 @@ func TestRangeTable(t *testing.T) {
 	set(15, 20, "d")
 	set(20, 25, "e")
 
-	dump(t, tb.segments)
+	dump(t, tb.segments())
 
 	check := func(expected any, sta, end int) {
 		for i := sta; i < end; i++ {
@@ -43,7 +46,7 @@ func TestRangeTable(t *testing.T) {
 		}
 
 		found := [NODES]bool{}
-		for _, it := range tb.segments {
+		for _, it := range tb.segments() {
 			expected := []any{}
 			actual := []any{}
 			for n := it.sta; n < it.end; n++ {
@@ -101,6 +104,145 @@ func TestRangeTable(t *testing.T) {
 	check(nil, 60, 70)
 }
 
+func TestRangeTableDebugLogging(t *testing.T) {
+	test := require.New(t)
+
+	out := &strings.Builder{}
+	tb := RangeTable{dbg: &debug.Flags{Bindings: 1, Output: out}}
+
+	src := &lexer.Source{}
+	set := func(sta, end int, val string) {
+		span := src.Span()
+		span.Sta = sta
+		span.End = end
+		tb.Bind(span, core.Value{}, core.Value{}, val)
+	}
+
+	set(0, 10, "a")
+	set(20, 30, "b")
+	out.Reset()
+
+	// a new binding that only partially overlaps "a" and "b" (it contains
+	// neither, and is contained by neither) overrides both -- binding.overrides
+	// only skips the override when the *existing* binding is the more
+	// specific one (fully contained in the new one). The overridden tail of
+	// "a", the gap-fill in between, and the overridden head of "b" all end
+	// up under the same new binding and adjoin, so they merge into one run.
+	set(5, 25, "z")
+	test.Contains(out.String(), "[bindings] override")
+	test.Contains(out.String(), "[bindings] merge")
+}
+
+// TestRangeTableOverrideThenInvalidateRestoresPartition binds "a" over the
+// whole range, overrides a sub-range with "b", then invalidates exactly that
+// sub-range -- Invalidate's restore must put things back exactly as they
+// were before the override: one partition of "a", covering every node
+// exactly once, with no gap and no overlap. A restored segment snapshotted
+// with the wrong bounds (e.g. the override's pre-split range instead of just
+// what it displaced) would instead leave overlapping segments and duplicate
+// nodes behind.
+func TestRangeTableOverrideThenInvalidateRestoresPartition(t *testing.T) {
+	test := require.New(t)
+
+	const NODES = 10
+
+	tb := RangeTable{}
+	nodes := make([]*Node, NODES)
+	for i := 0; i < NODES; i++ {
+		nodes[i] = NewNode(core.Value{}, lexer.Span{Sta: i})
+		tb.Add(nodes[i])
+	}
+
+	src := &lexer.Source{}
+	set := func(sta, end int, val string) {
+		span := src.Span()
+		span.Sta, span.End = sta, end
+		tb.Bind(span, core.Value{}, core.Value{}, val)
+	}
+
+	set(0, 10, "a")
+	set(3, 7, "b")
+	tb.Invalidate(nil, 3, 7)
+
+	seen := [NODES]bool{}
+	for _, seg := range tb.segments() {
+		test.Equal("a", seg.bind.val, "segment %d..%d should have reverted to `a`", seg.sta, seg.end)
+		for _, node := range seg.list {
+			pos := node.Offset()
+			test.False(seen[pos], "node #%d restored more than once", pos)
+			seen[pos] = true
+		}
+	}
+	for i, ok := range seen {
+		test.True(ok, "node #%d missing after restore", i)
+	}
+
+	for i := 0; i < NODES; i++ {
+		test.Equal("a", tb.Get(i), "offset %d should read back as `a`", i)
+	}
+
+	segs := tb.segments()
+	for i := 1; i < len(segs); i++ {
+		test.True(segs[i-1].end <= segs[i].sta, "segments %d..%d and %d..%d overlap", segs[i-1].sta, segs[i-1].end, segs[i].sta, segs[i].end)
+	}
+}
+
+// TestRangeTableStress generates 100k disjoint spans, binds roughly four in
+// five of them to a RangeTable in random order -- exercising the treap's split/merge under arbitrary insertion
+// order, not just the append-at-the-end pattern a sequential lexer pass
+// would produce -- and checks every lookup against a reference built by
+// binary-searching a plain sorted slice, a model that never touches
+// RangeTable's own tree or segment machinery.
+func TestRangeTableStress(t *testing.T) {
+	test := require.New(t)
+
+	const N = 100_000
+	rng := rand.New(rand.NewSource(1))
+
+	type span struct {
+		sta, end int
+		val      int
+	}
+
+	var bound []span
+	pos := 0
+	for i := 0; i < N; i++ {
+		pos += rng.Intn(4)
+		sta := pos
+		pos += rng.Intn(8) + 1
+		end := pos
+		if rng.Intn(5) != 0 { // leave some gaps unbound
+			bound = append(bound, span{sta, end, i})
+		}
+	}
+
+	order := rng.Perm(len(bound))
+
+	tb := RangeTable{}
+	src := &lexer.Source{}
+	for _, idx := range order {
+		s := bound[idx]
+		span := src.Span()
+		span.Sta, span.End = s.sta, s.end
+		tb.Bind(span, core.Value{}, core.Value{}, s.val)
+	}
+
+	reference := func(p int) any {
+		idx := sort.Search(len(bound), func(i int) bool {
+			return bound[i].end > p
+		})
+		if idx < len(bound) && p >= bound[idx].sta {
+			return bound[idx].val
+		}
+		return nil
+	}
+
+	for i := 0; i < 5_000; i++ {
+		p := rng.Intn(pos + 1)
+		test.Equal(reference(p), tb.Get(p), "mismatch at offset %d", p)
+	}
+}
+
 func dump(t *testing.T, segments []*segment) {
 	output := strings.Builder{}
 	for n, it := range segments {
@@ -12,6 +12,15 @@ import (
 
 type NodeList struct {
 	nodes []*Node
+	views []observer
+}
+
+// subscribe registers o to be notified, via onChange, whenever ls.nodes
+// changes at or after some index -- see updateFrom. Used by View and Group
+// to stay in sync with ls without re-scanning it from scratch on every
+// edit.
+func (ls *NodeList) subscribe(o observer) {
+	ls.views = append(ls.views, o)
 }
 
 func (ls *NodeList) Len() int {
@@ -73,7 +82,7 @@ func (ls *NodeList) Extract(sta, end int) *NodeList {
 		ls.nodes = append(ls.nodes[:sta], ls.nodes[end:]...)
 		ls.updateFrom(sta)
 	}
-	out := &NodeList{nodes}
+	out := &NodeList{nodes: nodes}
 	out.updateFrom(0)
 	return out
 }
@@ -83,6 +92,9 @@ func (ls *NodeList) updateFrom(index int) {
 		ls.nodes[i].list = ls
 		ls.nodes[i].index = i
 	}
+	for _, v := range ls.views {
+		v.onChange(index)
+	}
 }
 
 func (ls *NodeList) String() string {
@@ -148,6 +160,17 @@ func (node *Node) Offset() int {
 	return node.span.Sta
 }
 
+// shiftOffset moves the node's span by `delta` bytes and `rowDelta` lines if
+// it starts at or past `pos`, used to keep spans in sync after a
+// length-changing source edit.
+func (node *Node) shiftOffset(pos, delta, rowDelta int) {
+	if node.span.Sta >= pos {
+		node.span.Sta += delta
+		node.span.End += delta
+		node.span.Row += rowDelta
+	}
+}
+
 func (node *Node) Value() core.Value {
 	return node.val
 }
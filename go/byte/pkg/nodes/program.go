@@ -7,8 +7,10 @@ import (
 	"sort"
 	"strings"
 	"sync"
+	"time"
 
 	"axlab.dev/byte/pkg/core"
+	"axlab.dev/byte/pkg/debug"
 	"axlab.dev/byte/pkg/lexer"
 	"axlab.dev/util"
 )
@@ -43,21 +45,32 @@ func (mod *Module) Source() *lexer.Source {
 	return mod.source
 }
 
+// InvalidateSpan reprocesses the given byte range of the module: bindings
+// covering the span are tombstoned, the outputs they produced are unwound,
+// and whatever they displaced is restored. The disturbed segments are
+// requeued so the next Program.Evaluate call only redoes the affected work.
+func (mod *Module) InvalidateSpan(span lexer.Span) {
+	mod.nodes.Invalidate(span)
+}
+
 type Program struct {
-	Debug  DebugFlags
-	Errors []Error
-
-	globals   map[core.Value]globalBind
-	lexer     lexer.Lexer
-	types     core.TypeMap
-	queue     nodeSetQueue
-	tabWidth  int
-	basePath  string
-	modulesRW sync.RWMutex
-	modules   map[*lexer.Source]*Module
-	sourcesRW sync.RWMutex
-	sources   map[string]sourceItem
-	modOrder  int
+	Debug   debug.Flags
+	Timings Timings
+	Errors  []Error
+
+	globals     map[core.Value]globalBind
+	lexer       lexer.Lexer
+	types       core.TypeMap
+	queue       nodeSetQueue
+	tabWidth    int
+	basePath    string
+	parallelism int
+	mmapSources bool
+	modulesRW   sync.RWMutex
+	modules     map[*lexer.Source]*Module
+	sourcesRW   sync.RWMutex
+	sources     map[string]sourceItem
+	modOrder    int
 }
 
 type globalBind struct {
@@ -65,8 +78,54 @@ type globalBind struct {
 	op  Operator
 }
 
-type DebugFlags struct {
-	Enable bool
+// Timings records wall time spent in each phase of the compile pipeline, so
+// Program.SetDebug("timings=1") can print a profile-like summary without
+// wiring up pprof. Program sets Load, Evaluate and Modules; it never sets
+// Lex, which stays zero unless a caller fills it in.
+type Timings struct {
+	Load     time.Duration
+	Lex      time.Duration
+	Evaluate time.Duration
+	Modules  map[string]time.Duration
+}
+
+func (t *Timings) addModule(name string, d time.Duration) {
+	if t.Modules == nil {
+		t.Modules = make(map[string]time.Duration)
+	}
+	t.Modules[name] += d
+}
+
+func (t *Timings) String() string {
+	out := strings.Builder{}
+	out.WriteString("Timings:\n")
+	out.WriteString(fmt.Sprintf("  load:     %s\n", t.Load))
+	out.WriteString(fmt.Sprintf("  lex:      %s\n", t.Lex))
+	out.WriteString(fmt.Sprintf("  evaluate: %s\n", t.Evaluate))
+
+	names := make([]string, 0, len(t.Modules))
+	for name := range t.Modules {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		out.WriteString(fmt.Sprintf("  module %s: %s\n", name, t.Modules[name]))
+	}
+
+	return out.String()
+}
+
+// SetDebug parses spec (see debug.Parse) and installs the resulting flags,
+// wiring them into the lexer and node-set machinery that this Program owns.
+func (prog *Program) SetDebug(spec string) error {
+	flags, err := debug.Parse(spec)
+	if err != nil {
+		return err
+	}
+	prog.Debug = flags
+	prog.lexer.SetDebug(&prog.Debug)
+	prog.queue.dbg = &prog.Debug
+	return nil
 }
 
 type sourceItem struct {
@@ -82,6 +141,20 @@ func (prog *Program) SetTabWidth(tabWidth int) {
 	prog.tabWidth = tabWidth
 }
 
+// SetParallelism sets how many segments Evaluate processes concurrently
+// within a single `ord` epoch. n <= 0 means sequential (the default).
+func (prog *Program) SetParallelism(n int) {
+	prog.parallelism = n
+}
+
+// SetMmapSources controls whether LoadSource backs a file's text with a
+// memory-mapped view (lexer.OpenSourceMmap) instead of reading it fully into
+// memory (the default). Once enabled, the Program's sources must be released
+// with Close when no longer needed.
+func (prog *Program) SetMmapSources(enabled bool) {
+	prog.mmapSources = enabled
+}
+
 func (prog *Program) Types() *core.TypeMap {
 	return &prog.types
 }
@@ -94,6 +167,9 @@ func (prog *Program) Bind(key, ord core.Value, op Operator) {
 }
 
 func (prog *Program) LoadString(name, text string) *Module {
+	sta := time.Now()
+	defer func() { prog.Timings.Load += time.Since(sta) }()
+
 	src := &lexer.Source{
 		Name: name,
 		Text: text,
@@ -103,6 +179,9 @@ func (prog *Program) LoadString(name, text string) *Module {
 }
 
 func (prog *Program) LoadSource(file string) (mod *Module, err error) {
+	sta := time.Now()
+	defer func() { prog.Timings.Load += time.Since(sta) }()
+
 	prog.sourcesRW.Lock()
 	defer prog.sourcesRW.Unlock()
 
@@ -133,7 +212,11 @@ func (prog *Program) LoadSource(file string) (mod *Module, err error) {
 
 	if name, err = filepath.Rel(base, file); err == nil {
 		name = strings.Replace(name, "\\", "/", -1)
-		if text, err = os.ReadFile(file); err == nil {
+		if prog.mmapSources {
+			if src, err = lexer.OpenSourceMmap(file); err == nil {
+				src.Name, src.TabW = name, prog.tabWidth
+			}
+		} else if text, err = os.ReadFile(file); err == nil {
 			src = &lexer.Source{Name: name, Text: string(text), TabW: prog.tabWidth}
 		}
 	}
@@ -145,13 +228,74 @@ func (prog *Program) LoadSource(file string) (mod *Module, err error) {
 	return
 }
 
+// ReloadString replaces a module's source text in place and reprocesses
+// only the changed range, so a caller re-running evaluation after a small
+// edit pays roughly the cost of that edit rather than a full rebuild.
+func (prog *Program) ReloadString(mod *Module, text string) {
+	old := mod.source.Text
+	sta, oldEnd, newEnd := diffRange(old, text)
+
+	edit := mod.source.ApplyEdit(sta, oldEnd-sta, text[sta:newEnd])
+	mod.source.Close()
+	mod.nodes.ShiftFrom(edit.OldEnd, edit.Delta(), edit.RowDelta)
+
+	span := mod.source.Span()
+	span.Sta, span.End = sta, newEnd
+	mod.InvalidateSpan(span)
+}
+
+// ReloadSource re-reads a file-backed module from disk and reloads it the
+// same way ReloadString does.
+func (prog *Program) ReloadSource(mod *Module) (err error) {
+	prog.sourcesRW.RLock()
+	file := ""
+	for path, item := range prog.sources {
+		if item.src == mod.source {
+			file = path
+			break
+		}
+	}
+	prog.sourcesRW.RUnlock()
+
+	if file == "" {
+		return fmt.Errorf("module `%s` is not backed by a file", mod.source.Name)
+	}
+
+	text, err := os.ReadFile(file)
+	if err == nil {
+		prog.ReloadString(mod, string(text))
+	}
+	return err
+}
+
+// diffRange finds the smallest [sta, end) range in `old` that differs from
+// `text`, returning the matching end offset in `text` as well.
+func diffRange(old, text string) (sta, oldEnd, newEnd int) {
+	minLen := len(old)
+	if len(text) < minLen {
+		minLen = len(text)
+	}
+
+	for sta < minLen && old[sta] == text[sta] {
+		sta++
+	}
+
+	oldEnd, newEnd = len(old), len(text)
+	for oldEnd > sta && newEnd > sta && old[oldEnd-1] == text[newEnd-1] {
+		oldEnd--
+		newEnd--
+	}
+
+	return
+}
+
 func (prog *Program) createModule(src *lexer.Source) *Module {
 	prog.modulesRW.Lock()
 	defer prog.modulesRW.Unlock()
 	module := &Module{
 		lexer:  prog.lexer.Clone(),
 		source: src,
-		nodes:  newNodeSet(&prog.types, &prog.queue),
+		nodes:  newNodeSet(&prog.types, &prog.queue, &prog.Debug),
 		order:  len(prog.modules) + 1,
 	}
 	if prog.modules == nil {
@@ -168,6 +312,14 @@ func (prog *Program) createModule(src *lexer.Source) *Module {
 }
 
 func (prog *Program) Evaluate() {
+	sta := time.Now()
+	defer func() {
+		prog.Timings.Evaluate += time.Since(sta)
+		if prog.Debug.Timings > 0 {
+			fmt.Fprint(prog.Debug.Writer(), prog.Timings.String())
+		}
+	}()
+
 	prog.modulesRW.RLock()
 	defer prog.modulesRW.RUnlock()
 
@@ -192,19 +344,20 @@ func (prog *Program) Evaluate() {
 	})
 
 	for _, mod := range modules {
+		modSta := time.Now()
+
 		mod.source.Sort = prog.modOrder + 1
 		prog.modOrder++
 		node := NewNode(mod.source.AsValue(prog.Types()), mod.source.Span())
 		mod.main = &NodeList{}
 		mod.main.Add(node)
 		mod.nodes.Add(node)
-	}
 
-	for prog.queue.Len() > 0 {
-		segment := prog.queue.Shift()
-		panic(fmt.Sprintf("TODO: %s", segment.String()))
+		prog.Timings.addModule(mod.source.Name, time.Since(modSta))
 	}
 
+	prog.runQueue()
+
 	for _, mod := range modules {
 		if keys, vals := mod.nodes.PopUnbound(); len(keys) > 0 {
 			err := strings.Builder{}
@@ -222,6 +375,105 @@ func (prog *Program) Evaluate() {
 	}
 }
 
+// runQueue drains prog.queue in "ord" epochs: each batch gatherEpoch returns
+// is dispatched to a pool of prog.parallelism goroutines, so Operators
+// for independent ranges can run concurrently while still respecting the
+// ordering the queue enforces across epochs. A sequential run (the
+// default, prog.parallelism <= 1) still gathers a whole batch, then runs its
+// segments one at a time.
+func (prog *Program) runQueue() {
+	workers := prog.parallelism
+	if workers < 1 {
+		workers = 1
+	}
+
+	for prog.queue.Len() > 0 {
+		batch := prog.gatherEpoch()
+
+		var wg sync.WaitGroup
+		sem := make(chan struct{}, workers)
+		for _, seg := range batch {
+			wg.Add(1)
+			sem <- struct{}{}
+			go func(seg Segment) {
+				defer wg.Done()
+				defer func() { <-sem }()
+				prog.runSegment(seg)
+			}(seg)
+		}
+		wg.Wait()
+	}
+}
+
+// gatherEpoch shifts segments off the head of prog.queue, in queue order,
+// for as long as each shares the head's `ord` and its [sta,end) range
+// doesn't overlap a segment already gathered for the same key; it stops at
+// the first segment that fails either test. The batch it returns can run
+// concurrently without two goroutines touching the same RangeTable range.
+func (prog *Program) gatherEpoch() (batch []Segment) {
+	head := prog.queue.Peek()
+	ord := head.bind.ord
+
+	type span struct {
+		key core.Value
+		end int
+	}
+	var occupied []span
+
+	overlaps := func(seg Segment) bool {
+		for _, it := range occupied {
+			if it.key == seg.bind.key && seg.sta < it.end {
+				return true
+			}
+		}
+		return false
+	}
+
+	for prog.queue.Len() > 0 {
+		next := prog.queue.Peek()
+		if next.bind.ord.Compare(ord) != 0 || overlaps(next) {
+			break
+		}
+
+		seg := prog.queue.Shift()
+		batch = append(batch, seg)
+		occupied = append(occupied, span{seg.bind.key, seg.end})
+	}
+
+	if len(batch) == 0 {
+		// the head itself never clears an empty `occupied` set, so this
+		// can only happen if prog.queue.Len() was already 0.
+		batch = append(batch, prog.queue.Shift())
+	}
+
+	return batch
+}
+
+// runSegment processes a single segment dispatched by runQueue. The
+// compile pipeline doesn't have Operator execution wired up yet (see the
+// TODO this replaces), so this is still a placeholder -- but it's now the
+// single choke point that a real dispatcher would hook into per-segment,
+// callable safely from multiple goroutines at once.
+func (prog *Program) runSegment(seg Segment) {
+	panic(fmt.Sprintf("TODO: %s", seg.String()))
+}
+
+// Close releases the resources backing every loaded module's source (e.g. an
+// mmap opened via lexer.OpenSourceMmap), returning the first error
+// encountered, if any. The Program itself remains usable for inspection
+// (Dump, SolvedModules) but its sources must not be read again afterward.
+func (prog *Program) Close() (err error) {
+	prog.modulesRW.RLock()
+	defer prog.modulesRW.RUnlock()
+
+	for _, mod := range prog.modules {
+		if e := mod.source.Close(); e != nil && err == nil {
+			err = e
+		}
+	}
+	return err
+}
+
 func (prog *Program) Dump() {
 	for _, mod := range prog.SolvedModules() {
 		fmt.Println()
@@ -0,0 +1,226 @@
+package nodes
+
+import (
+	"container/heap"
+	"math/rand"
+	"sync"
+	"testing"
+
+	"axlab.dev/byte/pkg/core"
+	"axlab.dev/byte/pkg/lexer"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiffRange(t *testing.T) {
+	test := require.New(t)
+
+	check := func(old, text string, sta, oldEnd, newEnd int) {
+		s, oe, ne := diffRange(old, text)
+		test.Equal(sta, s, "sta for %q -> %q", old, text)
+		test.Equal(oldEnd, oe, "oldEnd for %q -> %q", old, text)
+		test.Equal(newEnd, ne, "newEnd for %q -> %q", old, text)
+		test.Equal(text[ne:], old[oe:], "tail after newEnd/oldEnd must match")
+		test.Equal(text[:s], old[:s], "head before sta must match")
+	}
+
+	// identical text: nothing differs, the range collapses to empty at the end
+	check("abc", "abc", 3, 3, 3)
+
+	// pure insertion in the middle
+	check("abcdef", "abcXYZdef", 3, 3, 6)
+
+	// pure deletion in the middle
+	check("abcXYZdef", "abcdef", 3, 6, 3)
+
+	// replacement in the middle, same length
+	check("abcdef", "abcXYf", 3, 5, 5)
+
+	// entirely different strings share no common prefix or suffix
+	check("abc", "xyz", 0, 3, 3)
+
+	// appending to the end shares the whole old string as a common prefix
+	check("abc", "abcdef", 3, 3, 6)
+
+	// empty strings
+	check("", "", 0, 0, 0)
+	check("", "abc", 0, 0, 3)
+	check("abc", "", 0, 3, 0)
+}
+
+// TestGatherEpoch exercises gatherEpoch's batching directly against
+// prog.queue, without going through NodeSet.Bind or the (still unimplemented)
+// runSegment worker -- it's the pure batching/overlap logic gatherEpoch asks
+// for that's being covered here, not a full Evaluate run.
+func TestGatherEpoch(t *testing.T) {
+	test := require.New(t)
+
+	types := &core.TypeMap{}
+	src := &lexer.Source{}
+
+	ordA := types.NewInt(1)
+	ordB := types.NewInt(2)
+	keyX := types.NewInt(100)
+	keyY := types.NewInt(200)
+
+	push := func(prog *Program, sta, end int, key, ord core.Value) *segment {
+		seg := &segment{
+			sta:  sta,
+			end:  end,
+			bind: &binding{sta: sta, end: end, src: src, key: key, ord: ord},
+			list: []*Node{NewNode(core.Value{}, lexer.Span{})},
+		}
+		heap.Push(&prog.queue, seg)
+		return seg
+	}
+
+	prog := &Program{}
+	seg1 := push(prog, 0, 10, keyX, ordA)
+	segOverlap := push(prog, 5, 15, keyX, ordA)
+	seg3 := push(prog, 20, 30, keyX, ordA)
+	seg2 := push(prog, 0, 10, keyY, ordA)
+	seg4 := push(prog, 0, 10, keyX, ordB)
+
+	// within keyX, segments are drained front-to-back in sta order: seg1
+	// gathers fine, but segOverlap overlaps it for the same key, so
+	// gatherEpoch stops there for this epoch even though seg3 (further back
+	// in the queue) wouldn't itself have overlapped anything gathered so far.
+	batch := prog.gatherEpoch()
+	test.Len(batch, 1)
+	test.Equal(seg1.sta, batch[0].sta)
+	test.Equal(seg1.end, batch[0].end)
+
+	// segOverlap is now the head; nothing left in its way for this epoch, so
+	// it batches together with seg3 (same key, no overlap with segOverlap)
+	// and seg2 (different key at ordA entirely).
+	batch = prog.gatherEpoch()
+	test.Len(batch, 3)
+	test.Equal(segOverlap.sta, batch[0].sta)
+	test.Equal(seg3.sta, batch[1].sta)
+	test.Equal(seg2.sta, batch[2].sta)
+
+	// the ordB segment only ever forms its own, later epoch.
+	batch = prog.gatherEpoch()
+	test.Len(batch, 1)
+	test.Equal(seg4.sta, batch[0].sta)
+	test.True(batch[0].bind.ord.Compare(ordB) == 0)
+
+	test.Equal(0, prog.queue.Len())
+}
+
+// TestGatherEpochRandomizedNoOverlap pushes the same segments onto
+// prog.queue in many random orders -- standing in for the "shuffled
+// operator execution order" the parallel dispatcher in runQueue needs to
+// tolerate -- and checks gatherEpoch's batching invariant holds regardless
+// of arrival order: every batch it hands runQueue only ever contains
+// segments for the same key that don't overlap, so two goroutines can never
+// be dispatched onto overlapping ranges of the same RangeTable. It can't go
+// further and compare a full Program.Evaluate run against a sequential
+// baseline, since runSegment has no Operator dispatch wired up yet (it's
+// still the TODO placeholder) -- there's no SolvedModules() output to
+// diff until that lands.
+func TestGatherEpochRandomizedNoOverlap(t *testing.T) {
+	test := require.New(t)
+
+	types := &core.TypeMap{}
+	src := &lexer.Source{}
+
+	type want struct {
+		sta, end int
+		key, ord core.Value
+	}
+
+	var specs []want
+	for ord := 0; ord < 3; ord++ {
+		for key := 0; key < 3; key++ {
+			for sta := 0; sta < 50; sta += 10 {
+				specs = append(specs, want{sta, sta + 10, types.NewInt(key), types.NewInt(ord)})
+			}
+		}
+	}
+
+	rng := rand.New(rand.NewSource(1))
+	for trial := 0; trial < 20; trial++ {
+		order := rng.Perm(len(specs))
+
+		prog := &Program{}
+		for _, idx := range order {
+			s := specs[idx]
+			heap.Push(&prog.queue, &segment{
+				sta:  s.sta,
+				end:  s.end,
+				bind: &binding{sta: s.sta, end: s.end, src: src, key: s.key, ord: s.ord},
+				list: []*Node{NewNode(core.Value{}, lexer.Span{})},
+			})
+		}
+
+		seen := map[core.Value]int{}
+		for prog.queue.Len() > 0 {
+			batch := prog.gatherEpoch()
+
+			type occupied struct {
+				key      core.Value
+				sta, end int
+			}
+			var ranges []occupied
+			for _, seg := range batch {
+				for _, prior := range ranges {
+					overlaps := seg.bind.key.Compare(prior.key) == 0 && seg.sta < prior.end && prior.sta < seg.end
+					test.False(overlaps, "trial %d: batch contains overlapping same-key segments %d..%d and %d..%d", trial, seg.sta, seg.end, prior.sta, prior.end)
+				}
+				ranges = append(ranges, occupied{seg.bind.key, seg.sta, seg.end})
+				seen[seg.bind.key] = seen[seg.bind.key] + 1
+			}
+		}
+
+		for key := 0; key < 3; key++ {
+			test.Equal(15, seen[types.NewInt(key)], "trial %d: key %d should have been drained exactly once per segment", trial, key)
+		}
+	}
+}
+
+// TestNodeSetConcurrentBind binds many disjoint spans on the same
+// NodeSet from many goroutines at once -- run with `go test -race` to
+// confirm the per-key RangeTable locking addBinding relies on actually
+// guards the Bind path. The final table must match running the same
+// binds sequentially: concurrency is only allowed to reorder when things
+// happen, never what ends up bound.
+func TestNodeSetConcurrentBind(t *testing.T) {
+	test := require.New(t)
+
+	types := &core.TypeMap{}
+	key := types.NewInt(1)
+	ord := types.NewInt(1)
+
+	const SPANS = 50
+
+	run := func(concurrent bool) *NodeSet {
+		set := newNodeSet(types, &nodeSetQueue{}, nil)
+
+		var wg sync.WaitGroup
+		for i := 0; i < SPANS; i++ {
+			sta := i * 2
+			span := lexer.Span{Sta: sta, End: sta + 2}
+			do := func() { set.Bind(span, key, ord, i) }
+			if concurrent {
+				wg.Add(1)
+				go func() { defer wg.Done(); do() }()
+			} else {
+				do()
+			}
+		}
+		wg.Wait()
+		return set
+	}
+
+	sequential := run(false)
+	concurrentSet := run(true)
+
+	tb, ok := sequential.lookupTable(key)
+	test.True(ok)
+	other, ok := concurrentSet.lookupTable(key)
+	test.True(ok)
+
+	for i := 0; i < SPANS*2; i++ {
+		test.Equal(tb.Get(i), other.Get(i), "offset %d diverged between sequential and concurrent runs", i)
+	}
+}
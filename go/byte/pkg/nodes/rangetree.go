@@ -0,0 +1,146 @@
+package nodes
+
+import "math/rand"
+
+// segNode is a node in the treap indexing a RangeTable's segments by sta.
+// Segments are non-overlapping and sorted, so the tree doubles as a plain
+// BST over sta for point lookups while priority keeps it balanced in
+// expectation regardless of insertion order.
+type segNode struct {
+	seg      *segment
+	priority int64
+	left     *segNode
+	right    *segNode
+}
+
+func newSegNode(seg *segment) *segNode {
+	return &segNode{seg: seg, priority: rand.Int63()}
+}
+
+// treapSearch returns the segment covering pos, or nil if none does.
+func treapSearch(root *segNode, pos int) *segment {
+	for root != nil {
+		switch {
+		case pos < root.seg.sta:
+			root = root.left
+		case pos >= root.seg.end:
+			root = root.right
+		default:
+			return root.seg
+		}
+	}
+	return nil
+}
+
+// treapRightmost returns the last (highest-sta) segment in root, or nil if
+// root is empty.
+func treapRightmost(root *segNode) *segment {
+	if root == nil {
+		return nil
+	}
+	for root.right != nil {
+		root = root.right
+	}
+	return root.seg
+}
+
+// treapRemoveRightmost returns root with its last segment removed.
+func treapRemoveRightmost(root *segNode) *segNode {
+	if root == nil {
+		return nil
+	}
+	if root.right == nil {
+		return root.left
+	}
+	root.right = treapRemoveRightmost(root.right)
+	return root
+}
+
+// treapSplitByEnd splits root into (left, right) so left holds every segment
+// with end <= at and right holds the rest. Segment ends are non-decreasing
+// in sta order, so this predicate is monotonic across the tree.
+func treapSplitByEnd(root *segNode, at int) (left, right *segNode) {
+	if root == nil {
+		return nil, nil
+	}
+	if root.seg.end <= at {
+		l, r := treapSplitByEnd(root.right, at)
+		root.right = l
+		return root, r
+	}
+	l, r := treapSplitByEnd(root.left, at)
+	root.left = r
+	return l, root
+}
+
+// treapSplitBySta splits root into (left, right) so left holds every segment
+// with sta < at and right holds the rest.
+func treapSplitBySta(root *segNode, at int) (left, right *segNode) {
+	if root == nil {
+		return nil, nil
+	}
+	if root.seg.sta < at {
+		l, r := treapSplitBySta(root.right, at)
+		root.right = l
+		return root, r
+	}
+	l, r := treapSplitBySta(root.left, at)
+	root.left = r
+	return l, root
+}
+
+// treapMerge joins lhs and rhs into one treap, assuming every segment in lhs
+// sorts before every segment in rhs.
+func treapMerge(lhs, rhs *segNode) *segNode {
+	if lhs == nil {
+		return rhs
+	}
+	if rhs == nil {
+		return lhs
+	}
+	if lhs.priority > rhs.priority {
+		lhs.right = treapMerge(lhs.right, rhs)
+		return lhs
+	}
+	rhs.left = treapMerge(lhs, rhs.left)
+	return rhs
+}
+
+// treapBuild builds a treap from segs, which must already be sorted in
+// ascending sta order, in O(len(segs)) via the standard sorted-array
+// cartesian-tree construction.
+func treapBuild(segs []*segment) *segNode {
+	stack := make([]*segNode, 0, len(segs))
+	for _, seg := range segs {
+		node := newSegNode(seg)
+		var last *segNode
+		for len(stack) > 0 && stack[len(stack)-1].priority < node.priority {
+			last = stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+		}
+		node.left = last
+		if len(stack) > 0 {
+			stack[len(stack)-1].right = node
+		}
+		stack = append(stack, node)
+	}
+	if len(stack) == 0 {
+		return nil
+	}
+	return stack[0]
+}
+
+// treapCollect returns every segment in root, in ascending sta order.
+func treapCollect(root *segNode) (out []*segment) {
+	var walk func(*segNode)
+	walk = func(n *segNode) {
+		if n == nil {
+			return
+		}
+		walk(n.left)
+		out = append(out, n.seg)
+		walk(n.right)
+	}
+	walk(root)
+	return
+}
@@ -0,0 +1,230 @@
+package nodes
+
+import (
+	"sort"
+
+	"axlab.dev/byte/pkg/core"
+)
+
+// nodeSource is what a View or Group is derived from -- a NodeList, or
+// another View, both of which can be read by index and notify observer
+// when their nodes change.
+type nodeSource interface {
+	Len() int
+	Get(i int) *Node
+	subscribe(o observer)
+}
+
+// observer is notified whenever a nodeSource's nodes at or after `sta`
+// change (inserted, removed, or just reindexed by an edit earlier in the
+// list). It re-derives only what that range affects rather than rebuild
+// from scratch -- see View.onChange and Group.onChange.
+type observer interface {
+	onChange(sta int)
+}
+
+// View is an incrementally-maintained, read-only projection of a NodeList
+// or of another View -- NodeList.Select and NodeList.SortBy both produce
+// one. It exposes the same Len/Get/Nodes surface NodeList does, so it
+// composes (SortBy over a Select only resorts the nodes the Select kept)
+// and a downstream pass can consume either without caring which.
+//
+// A View subscribes to its source and keeps an index of Node.Key() -> the
+// positions of every node with that key (Key() classifies a node's
+// construct, e.g. for the binding pipeline, rather than identifying it
+// uniquely -- the same key routinely covers many nodes) for ByKey. The
+// index is rebuilt in full on every onChange; what keeps a change local is
+// refresh, which re-derives only the nodes whose Index() is at or after the
+// changed position.
+type View struct {
+	source nodeSource
+	nodes  []*Node
+	index  map[core.Value][]int
+	views  []observer
+
+	// refresh re-derives v.nodes for everything source changed at or after
+	// sta, leaving nodes.Index() < sta untouched. Select and SortBy each
+	// install their own closure over the predicate/key they were built with.
+	refresh func(sta int)
+}
+
+func (v *View) Len() int        { return len(v.nodes) }
+func (v *View) Get(i int) *Node { return v.nodes[i] }
+func (v *View) Nodes() []*Node  { return v.nodes }
+
+// ByKey returns every node in this view with the given key, in view order.
+func (v *View) ByKey(key core.Value) (out []*Node) {
+	for _, i := range v.index[key] {
+		out = append(out, v.nodes[i])
+	}
+	return out
+}
+
+func (v *View) subscribe(o observer) {
+	v.views = append(v.views, o)
+}
+
+func (v *View) onChange(sta int) {
+	v.refresh(sta)
+
+	v.index = make(map[core.Value][]int, len(v.nodes))
+	for i, n := range v.nodes {
+		key := n.Key()
+		v.index[key] = append(v.index[key], i)
+	}
+
+	// sta is a position in the underlying NodeList, not in this view, so
+	// views built on top of this one re-derive from the start
+	for _, o := range v.views {
+		o.onChange(0)
+	}
+}
+
+// compactByIndex drops every node at or after sta (including any already
+// removed from its owning list, whose Index() is -1), keeping only what's
+// still valid and unaffected by the change. extra, if non-nil, is a
+// parallel slice (e.g. SortBy's computed keys) kept in sync with the same
+// compaction.
+func compactByIndex[T any](nodes []*Node, extra []T, sta int) ([]*Node, []T) {
+	n := 0
+	for i, node := range nodes {
+		if idx := node.Index(); idx >= 0 && idx < sta {
+			nodes[n] = node
+			if extra != nil {
+				extra[n] = extra[i]
+			}
+			n++
+		}
+	}
+	if extra != nil {
+		extra = extra[:n]
+	}
+	return nodes[:n], extra
+}
+
+// Select returns a View holding the nodes of ls for which pred returns
+// true, kept in sync as ls changes: Add/Insert/RemoveAt only re-applies
+// pred to the nodes at or after the edit, not the whole list.
+func (ls *NodeList) Select(pred func(*Node) bool) *View {
+	return newSelectView(ls, pred)
+}
+
+// Select narrows this view further, composing with whatever produced it.
+func (v *View) Select(pred func(*Node) bool) *View {
+	return newSelectView(v, pred)
+}
+
+func newSelectView(source nodeSource, pred func(*Node) bool) *View {
+	v := &View{source: source}
+	v.refresh = func(sta int) {
+		v.nodes, _ = compactByIndex[struct{}](v.nodes, nil, sta)
+		for i := sta; i < source.Len(); i++ {
+			if n := source.Get(i); pred(n) {
+				v.nodes = append(v.nodes, n)
+			}
+		}
+	}
+	source.subscribe(v)
+	v.onChange(0)
+	return v
+}
+
+// SortBy returns a View holding every node of ls ordered by key, kept in
+// sync as ls changes: an edit only recomputes key for the nodes at or
+// after it before re-sorting. key must produce values whose types share a
+// registered comparator -- see core.Value.Compare -- the same requirement
+// any other caller of Compare has.
+func (ls *NodeList) SortBy(key func(*Node) core.Value) *View {
+	return newSortView(ls, key)
+}
+
+// SortBy reorders this view further, composing with whatever produced it.
+func (v *View) SortBy(key func(*Node) core.Value) *View {
+	return newSortView(v, key)
+}
+
+func newSortView(source nodeSource, key func(*Node) core.Value) *View {
+	var keys []core.Value
+	v := &View{source: source}
+	v.refresh = func(sta int) {
+		v.nodes, keys = compactByIndex(v.nodes, keys, sta)
+		for i := sta; i < source.Len(); i++ {
+			n := source.Get(i)
+			v.nodes = append(v.nodes, n)
+			keys = append(keys, key(n))
+		}
+		sort.Stable(sortByKeys{v.nodes, keys})
+	}
+	source.subscribe(v)
+	v.onChange(0)
+	return v
+}
+
+type sortByKeys struct {
+	nodes []*Node
+	keys  []core.Value
+}
+
+func (s sortByKeys) Len() int      { return len(s.nodes) }
+func (s sortByKeys) Less(i, j int) bool {
+	return s.keys[i].Compare(s.keys[j]) < 0
+}
+func (s sortByKeys) Swap(i, j int) {
+	s.nodes[i], s.nodes[j] = s.nodes[j], s.nodes[i]
+	s.keys[i], s.keys[j] = s.keys[j], s.keys[i]
+}
+
+// Group is the result of NodeList.GroupBy: an incrementally-maintained
+// partition of a list's nodes by a key function. Each key's nodes are kept
+// as a *View -- built the same way Select's is -- lazily created the first
+// time a node produces that key.
+type Group struct {
+	source nodeSource
+	keyFn  func(*Node) core.Value
+	order  []core.Value
+	byKey  map[core.Value]*View
+}
+
+// GroupBy partitions ls by key, kept in sync as ls changes.
+func (ls *NodeList) GroupBy(key func(*Node) core.Value) *Group {
+	return newGroup(ls, key)
+}
+
+// GroupBy partitions this view further, composing with whatever produced it.
+func (v *View) GroupBy(key func(*Node) core.Value) *Group {
+	return newGroup(v, key)
+}
+
+func newGroup(source nodeSource, key func(*Node) core.Value) *Group {
+	g := &Group{source: source, keyFn: key, byKey: map[core.Value]*View{}}
+	source.subscribe(g)
+	g.onChange(0)
+	return g
+}
+
+// Keys returns every distinct key seen so far, in the order each first
+// appeared.
+func (g *Group) Keys() []core.Value {
+	return g.order
+}
+
+// View returns the nodes grouped under key, or nil if no node has produced
+// that key yet.
+func (g *Group) View(key core.Value) *View {
+	return g.byKey[key]
+}
+
+func (g *Group) onChange(sta int) {
+	for i := sta; i < g.source.Len(); i++ {
+		key := g.keyFn(g.source.Get(i))
+		if _, ok := g.byKey[key]; !ok {
+			g.order = append(g.order, key)
+			g.byKey[key] = newSelectView(g.source, func(n *Node) bool {
+				return g.keyFn(n) == key
+			})
+		}
+	}
+	for _, key := range g.order {
+		g.byKey[key].onChange(sta)
+	}
+}
@@ -0,0 +1,119 @@
+package nodes
+
+import (
+	"testing"
+
+	"axlab.dev/byte/pkg/core"
+	"axlab.dev/byte/pkg/lexer"
+	"github.com/stretchr/testify/require"
+)
+
+func buildViewList(types *core.TypeMap, words ...string) *NodeList {
+	list := &NodeList{}
+	for _, w := range words {
+		list.Add(NewNode(core.NewValue(types.Str(), w), lexer.Span{}))
+	}
+	return list
+}
+
+func viewWords(v *View) []string {
+	return nodeWords(v.Nodes())
+}
+
+func nodeWords(nodes []*Node) (out []string) {
+	for _, n := range nodes {
+		out = append(out, n.Value().String())
+	}
+	return out
+}
+
+func TestSelectTracksEdits(t *testing.T) {
+	test := require.New(t)
+
+	types := &core.TypeMap{}
+	list := buildViewList(types, "a", "bb", "ccc", "d")
+
+	long := list.Select(func(n *Node) bool {
+		return len(n.Value().String()) > 1
+	})
+	test.Equal([]string{"bb", "ccc"}, viewWords(long))
+
+	list.Insert(1, NewNode(core.NewValue(types.Str(), "ee"), lexer.Span{}))
+	test.Equal([]string{"ee", "bb", "ccc"}, viewWords(long))
+
+	list.RemoveAt(0) // list is now ee, bb, ccc, d
+	test.Equal([]string{"ee", "bb", "ccc"}, viewWords(long))
+
+	list.RemoveAt(0) // drop "ee"
+	test.Equal([]string{"bb", "ccc"}, viewWords(long))
+}
+
+func TestSortByTracksEdits(t *testing.T) {
+	test := require.New(t)
+
+	types := &core.TypeMap{}
+	list := buildViewList(types, "c", "a", "b")
+
+	byFirstRune := func(n *Node) core.Value {
+		return types.NewInt(int(n.Value().String()[0]))
+	}
+
+	sorted := list.SortBy(byFirstRune)
+	test.Equal([]string{"a", "b", "c"}, viewWords(sorted))
+
+	list.Add(NewNode(core.NewValue(types.Str(), "bb"), lexer.Span{}))
+	test.Equal([]string{"a", "b", "bb", "c"}, viewWords(sorted))
+}
+
+func TestSelectComposesWithSortBy(t *testing.T) {
+	test := require.New(t)
+
+	types := &core.TypeMap{}
+	list := buildViewList(types, "ccc", "a", "bb")
+
+	view := list.Select(func(n *Node) bool {
+		return len(n.Value().String()) > 1
+	}).SortBy(func(n *Node) core.Value {
+		return types.NewInt(len(n.Value().String()))
+	})
+	test.Equal([]string{"bb", "ccc"}, viewWords(view))
+}
+
+func TestGroupByTracksEdits(t *testing.T) {
+	test := require.New(t)
+
+	types := &core.TypeMap{}
+	list := buildViewList(types, "a", "bb", "cc", "d")
+
+	group := list.GroupBy(func(n *Node) core.Value {
+		return types.NewInt(len(n.Value().String()))
+	})
+
+	one := group.View(types.NewInt(1))
+	two := group.View(types.NewInt(2))
+	test.Equal([]string{"a", "d"}, viewWords(one))
+	test.Equal([]string{"bb", "cc"}, viewWords(two))
+	test.Nil(group.View(types.NewInt(3)))
+
+	list.Add(NewNode(core.NewValue(types.Str(), "eee"), lexer.Span{}))
+	three := group.View(types.NewInt(3))
+	test.NotNil(three)
+	test.Equal([]string{"eee"}, viewWords(three))
+
+	// a newly added short word should still land in the existing "one" view
+	list.Add(NewNode(core.NewValue(types.Str(), "f"), lexer.Span{}))
+	test.Equal([]string{"a", "d", "f"}, viewWords(one))
+}
+
+func TestViewByKey(t *testing.T) {
+	test := require.New(t)
+
+	types := &core.TypeMap{}
+	list := buildViewList(types, "a", "bb", "ccc")
+	view := list.Select(func(n *Node) bool { return true })
+
+	// plain string values don't declare a binding key (see GetKey), so they
+	// all share the zero Value as their key.
+	test.Equal([]string{"a", "bb", "ccc"}, nodeWords(view.ByKey(core.Value{})))
+	test.Empty(view.ByKey(core.NewValue(types.Str(), "nope")))
+}
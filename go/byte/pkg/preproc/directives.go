@@ -0,0 +1,267 @@
+package preproc
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"axlab.dev/byte/pkg/lexer"
+)
+
+// splitDirective reports whether line is a `#`-led directive line, and if so
+// splits it into the directive name, the name token's span (for error
+// reporting when there is nothing else to anchor on) and the remaining
+// tokens.
+func splitDirective(line []lexer.Token) (name string, nameSpan lexer.Span, rest []lexer.Token, ok bool) {
+	i := 0
+	for i < len(line) && line[i].Kind == lexer.TokenComment {
+		i++
+	}
+	if i >= len(line) || !isSymbol(line[i], "#") {
+		return "", lexer.Span{}, nil, false
+	}
+	hash := line[i].Span
+	i++
+
+	for i < len(line) && line[i].Kind == lexer.TokenComment {
+		i++
+	}
+	if i >= len(line) {
+		return "", hash, nil, true // bare `#`: the null directive
+	}
+	if line[i].Kind != lexer.TokenWord {
+		return "", hash, nil, false
+	}
+
+	return line[i].Span.Text(), line[i].Span, line[i+1:], true
+}
+
+func (p *Preprocessor) doIf(rest []lexer.Token) {
+	parentActive := p.active()
+	at := tokensSpanOr(rest, lexer.Span{})
+
+	taken, active := false, false
+	if parentActive && p.evalCond(rest, at) != 0 {
+		taken, active = true, true
+	}
+	p.cond = append(p.cond, condFrame{parentActive: parentActive, taken: taken, active: active, at: at})
+}
+
+func (p *Preprocessor) doIfdef(rest []lexer.Token, negate bool) {
+	parentActive := p.active()
+	name, at := firstIdent(rest)
+
+	taken, active := false, false
+	if parentActive {
+		defined := p.IsDefined(name)
+		if negate {
+			defined = !defined
+		}
+		if defined {
+			taken, active = true, true
+		}
+	}
+	p.cond = append(p.cond, condFrame{parentActive: parentActive, taken: taken, active: active, at: at})
+}
+
+func (p *Preprocessor) doElif(rest []lexer.Token) {
+	if len(p.cond) == 0 {
+		p.errorAt(tokensSpanOr(rest, lexer.Span{}), "#elif without #if")
+		return
+	}
+	top := &p.cond[len(p.cond)-1]
+	if top.sawElse {
+		p.errorAt(tokensSpanOr(rest, top.at), "#elif after #else")
+		return
+	}
+
+	switch {
+	case !top.parentActive, top.taken:
+		top.active = false
+	case p.evalCond(rest, top.at) != 0:
+		top.taken, top.active = true, true
+	default:
+		top.active = false
+	}
+}
+
+func (p *Preprocessor) doElse(rest []lexer.Token) {
+	if len(p.cond) == 0 {
+		p.errorAt(tokensSpanOr(rest, lexer.Span{}), "#else without #if")
+		return
+	}
+	top := &p.cond[len(p.cond)-1]
+	if top.sawElse {
+		p.errorAt(tokensSpanOr(rest, top.at), "#else after #else")
+		return
+	}
+
+	top.sawElse = true
+	if !top.parentActive || top.taken {
+		top.active = false
+	} else {
+		top.taken, top.active = true, true
+	}
+}
+
+func (p *Preprocessor) doEndif(rest []lexer.Token) {
+	if len(p.cond) == 0 {
+		p.errorAt(tokensSpanOr(rest, lexer.Span{}), "#endif without #if")
+		return
+	}
+	p.cond = p.cond[:len(p.cond)-1]
+}
+
+func (p *Preprocessor) doDefine(rest []lexer.Token) {
+	rest = skipComments(rest)
+	if len(rest) == 0 || rest[0].Kind != lexer.TokenWord {
+		p.errorAt(tokensSpanOr(rest, lexer.Span{}), "macro name missing")
+		return
+	}
+
+	name, body := rest[0], rest[1:]
+	m := &Macro{Name: name.Span.Text()}
+
+	if len(body) > 0 && isSymbol(body[0], "(") && body[0].Span.Sta == name.Span.End {
+		m.FuncLike = true
+		params, variadic, funcBody, errMsg := parseParams(body)
+		if errMsg != "" {
+			p.errorAt(name.Span, errMsg)
+			return
+		}
+		m.Params, m.Variadic, body = params, variadic, funcBody
+	}
+
+	m.Body = skipComments(body)
+	p.macros[m.Name] = m
+}
+
+// parseParams consumes a macro parameter list starting at the `(` in toks
+// and returns the parameter names, whether it ends in `...`, and the tokens
+// remaining after the closing `)`.
+func parseParams(toks []lexer.Token) (params []string, variadic bool, rest []lexer.Token, errMsg string) {
+	i := 1 // toks[0] is "("
+	for i < len(toks) {
+		t := toks[i]
+		switch {
+		case t.Kind == lexer.TokenComment:
+			i++
+		case isSymbol(t, ")"):
+			return params, variadic, toks[i+1:], ""
+		case isSymbol(t, "..."):
+			variadic = true
+			i++
+		case isSymbol(t, ","):
+			i++
+		case t.Kind == lexer.TokenWord:
+			params = append(params, t.Span.Text())
+			i++
+		default:
+			return nil, false, nil, fmt.Sprintf("unexpected token `%s` in macro parameter list", t.Span.Text())
+		}
+	}
+	return nil, false, nil, "unterminated macro parameter list"
+}
+
+func (p *Preprocessor) doUndef(rest []lexer.Token) {
+	rest = skipComments(rest)
+	if len(rest) == 0 || rest[0].Kind != lexer.TokenWord {
+		p.errorAt(tokensSpanOr(rest, lexer.Span{}), "macro name missing")
+		return
+	}
+	p.Undef(rest[0].Span.Text())
+}
+
+func (p *Preprocessor) doInclude(src *lexer.Source, rest []lexer.Token, next bool) []lexer.Token {
+	rest = skipComments(rest)
+	name, quoted, ok := includeTarget(rest)
+	if !ok {
+		p.errorAt(tokensSpanOr(rest, lexer.Span{}), `#include expects "FILE" or <FILE>`)
+		return nil
+	}
+	if p.resolve == nil {
+		p.errorAt(tokensSpanOr(rest, lexer.Span{}), "#include: no include resolver configured")
+		return nil
+	}
+
+	included, err := p.resolve(name, quoted, next, src)
+	if err != nil {
+		p.errorAt(tokensSpanOr(rest, lexer.Span{}), fmt.Sprintf("#include %q: %s", name, err))
+		return nil
+	}
+	return p.lex.Tokenize(included)
+}
+
+// includeTarget reads the `"file"` or `<file>` naming a #include target.
+// The angle-bracket form is read straight out of the underlying source text
+// rather than from tokens, since filenames like `sys/types.h` don't tokenize
+// cleanly under an arbitrary host Lexer configuration.
+func includeTarget(rest []lexer.Token) (name string, quoted bool, ok bool) {
+	if len(rest) == 0 {
+		return "", false, false
+	}
+
+	first := rest[0]
+	if first.Kind == lexer.TokenLiteral {
+		text := first.Span.Text()
+		if len(text) >= 2 && strings.HasPrefix(text, `"`) && strings.HasSuffix(text, `"`) {
+			return text[1 : len(text)-1], true, true
+		}
+		return "", false, false
+	}
+
+	if isSymbol(first, "<") {
+		raw := first.Span.Src.Text[first.Span.Sta:]
+		if idx := strings.IndexByte(raw, '>'); idx > 1 {
+			return raw[1:idx], false, true
+		}
+	}
+
+	return "", false, false
+}
+
+func (p *Preprocessor) doLine(rest []lexer.Token) {
+	rest = skipComments(rest)
+	if len(rest) == 0 || rest[0].Kind != lexer.TokenNumber {
+		p.errorAt(tokensSpanOr(rest, lexer.Span{}), "#line expects a line number")
+		return
+	}
+
+	n, err := strconv.Atoi(rest[0].Span.Text())
+	if err != nil {
+		p.errorAt(rest[0].Span, "#line: invalid line number")
+		return
+	}
+
+	mark := LineMark{Number: n, At: rest[0].Span}
+	if len(rest) > 1 {
+		if lit := skipComments(rest[1:]); len(lit) > 0 && lit[0].Kind == lexer.TokenLiteral {
+			text := lit[0].Span.Text()
+			if len(text) >= 2 {
+				mark.File = text[1 : len(text)-1]
+			}
+		}
+	}
+	p.Lines = append(p.Lines, mark)
+}
+
+func (p *Preprocessor) doError(rest []lexer.Token) {
+	p.errorAt(tokensSpanOr(rest, lexer.Span{}), "#error "+spellOut(rest))
+}
+
+func (p *Preprocessor) doPragma(rest []lexer.Token) {
+	rest = skipComments(rest)
+	p.Pragmas = append(p.Pragmas, Pragma{Text: spellOut(rest), At: tokensSpanOr(rest, lexer.Span{})})
+}
+
+func firstIdent(toks []lexer.Token) (name string, at lexer.Span) {
+	for _, t := range toks {
+		if t.Kind == lexer.TokenWord {
+			return t.Span.Text(), t.Span
+		}
+		if t.Kind != lexer.TokenComment {
+			return "", t.Span
+		}
+	}
+	return "", lexer.Span{}
+}
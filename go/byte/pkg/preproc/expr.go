@@ -0,0 +1,401 @@
+package preproc
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"axlab.dev/byte/pkg/core"
+	"axlab.dev/byte/pkg/lexer"
+)
+
+// evalCond evaluates the constant-expression tokens of an #if/#elif line,
+// reporting a diagnostic anchored at `at` and returning 0 on any error.
+func (p *Preprocessor) evalCond(rest []lexer.Token, at lexer.Span) int64 {
+	toks := p.expand(p.substDefined(skipComments(rest)), nil)
+	if len(toks) == 0 {
+		p.errorAt(at, "#if with no expression")
+		return 0
+	}
+
+	parser := &condParser{p: p, toks: toks}
+	val := parser.parseExpr()
+	if parser.err != "" {
+		p.errorAt(at, parser.err)
+		return 0
+	}
+	if parser.pos != len(parser.toks) {
+		p.errorAt(at, fmt.Sprintf("unexpected token `%s` in #if expression", parser.toks[parser.pos].Span.Text()))
+		return 0
+	}
+	return val.AsInt64()
+}
+
+// substDefined replaces `defined NAME` and `defined(NAME)` with 1 or 0
+// before macro expansion runs: `defined` tests the macro table as currently
+// written, not after its own operand has been substituted.
+func (p *Preprocessor) substDefined(toks []lexer.Token) []lexer.Token {
+	var out []lexer.Token
+	for i := 0; i < len(toks); i++ {
+		t := toks[i]
+		if t.Kind != lexer.TokenWord || t.Span.Text() != "defined" {
+			out = append(out, t)
+			continue
+		}
+
+		j := i + 1
+		paren := j < len(toks) && isSymbol(toks[j], "(")
+		if paren {
+			j++
+		}
+		if j >= len(toks) || toks[j].Kind != lexer.TokenWord {
+			out = append(out, t)
+			continue
+		}
+
+		name := toks[j].Span.Text()
+		j++
+		if paren {
+			if j >= len(toks) || !isSymbol(toks[j], ")") {
+				out = append(out, t)
+				continue
+			}
+			j++
+		}
+
+		out = append(out, intToken(boolInt(p.IsDefined(name)), t.Span))
+		i = j - 1
+	}
+	return out
+}
+
+func boolInt(b bool) int64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func intToken(val int64, at lexer.Span) lexer.Token {
+	text := strconv.FormatInt(val, 10)
+	origin := at
+	src := &lexer.Source{Name: "<const>", Text: text}
+	return lexer.Token{
+		Kind: lexer.TokenNumber,
+		Span: lexer.Span{Src: src, Sta: 0, End: len(text), Row: 1, Col: 1, Ind: 1, ExpandedFrom: &origin},
+	}
+}
+
+// condParser is a small recursive-descent parser for C's integer constant
+// expressions, built directly over the already macro-expanded token list of
+// an #if/#elif line. Every intermediate result is held as a core.Value int
+// (TypeMap.NewInt), but the arithmetic itself is done on Go int64 values,
+// not by core.Value, so overflow wraps as Go's int64 does.
+type condParser struct {
+	p    *Preprocessor
+	toks []lexer.Token
+	pos  int
+	err  string
+}
+
+func (c *condParser) types() *core.TypeMap {
+	return c.p.types
+}
+
+func (c *condParser) newInt(v int64) core.Value {
+	return c.types().NewInt(int(v))
+}
+
+func (c *condParser) fail(format string, args ...any) core.Value {
+	if c.err == "" {
+		c.err = fmt.Sprintf(format, args...)
+	}
+	return c.newInt(0)
+}
+
+func (c *condParser) peek() (lexer.Token, bool) {
+	if c.err != "" || c.pos >= len(c.toks) {
+		return lexer.Token{}, false
+	}
+	return c.toks[c.pos], true
+}
+
+func (c *condParser) acceptSymbol(text string) bool {
+	if t, ok := c.peek(); ok && isSymbol(t, text) {
+		c.pos++
+		return true
+	}
+	return false
+}
+
+func (c *condParser) parseExpr() core.Value {
+	return c.parseTernary()
+}
+
+func (c *condParser) parseTernary() core.Value {
+	cond := c.parseLogicalOr()
+	if !c.acceptSymbol("?") {
+		return cond
+	}
+	then := c.parseExpr()
+	if !c.acceptSymbol(":") {
+		return c.fail("expected `:` in `?:` expression")
+	}
+	otherwise := c.parseTernary()
+	if cond.AsInt64() != 0 {
+		return then
+	}
+	return otherwise
+}
+
+// binaryLevel is one left-associative precedence tier: ops lists the
+// accepted operator symbols and eval computes the result given which one
+// matched.
+type binaryLevel struct {
+	ops  []string
+	eval func(op string, a, b int64) int64
+}
+
+func (c *condParser) parseBinary(next func() core.Value, level binaryLevel) core.Value {
+	left := next()
+	for {
+		matched := ""
+		for _, op := range level.ops {
+			if t, ok := c.peek(); ok && isSymbol(t, op) {
+				matched = op
+				break
+			}
+		}
+		if matched == "" {
+			return left
+		}
+		c.pos++
+		right := next()
+		left = c.newInt(level.eval(matched, left.AsInt64(), right.AsInt64()))
+	}
+}
+
+func (c *condParser) parseLogicalOr() core.Value {
+	return c.parseBinary(c.parseLogicalAnd, binaryLevel{[]string{"||"}, func(op string, a, b int64) int64 { return boolInt(a != 0 || b != 0) }})
+}
+
+func (c *condParser) parseLogicalAnd() core.Value {
+	return c.parseBinary(c.parseBitOr, binaryLevel{[]string{"&&"}, func(op string, a, b int64) int64 { return boolInt(a != 0 && b != 0) }})
+}
+
+func (c *condParser) parseBitOr() core.Value {
+	return c.parseBinary(c.parseBitXor, binaryLevel{[]string{"|"}, func(op string, a, b int64) int64 { return a | b }})
+}
+
+func (c *condParser) parseBitXor() core.Value {
+	return c.parseBinary(c.parseBitAnd, binaryLevel{[]string{"^"}, func(op string, a, b int64) int64 { return a ^ b }})
+}
+
+func (c *condParser) parseBitAnd() core.Value {
+	return c.parseBinary(c.parseEquality, binaryLevel{[]string{"&"}, func(op string, a, b int64) int64 { return a & b }})
+}
+
+func (c *condParser) parseEquality() core.Value {
+	return c.parseBinary(c.parseRelational, binaryLevel{[]string{"==", "!="}, func(op string, a, b int64) int64 {
+		if op == "==" {
+			return boolInt(a == b)
+		}
+		return boolInt(a != b)
+	}})
+}
+
+func (c *condParser) parseRelational() core.Value {
+	left := c.parseShift()
+	for {
+		op := ""
+		for _, cand := range []string{"<=", ">=", "<", ">"} {
+			if t, ok := c.peek(); ok && isSymbol(t, cand) {
+				op = cand
+				break
+			}
+		}
+		if op == "" {
+			return left
+		}
+		c.pos++
+		right := c.parseShift()
+		a, b := left.AsInt64(), right.AsInt64()
+		var result bool
+		switch op {
+		case "<=":
+			result = a <= b
+		case ">=":
+			result = a >= b
+		case "<":
+			result = a < b
+		case ">":
+			result = a > b
+		}
+		left = c.newInt(boolInt(result))
+	}
+}
+
+func (c *condParser) parseShift() core.Value {
+	return c.parseBinary(c.parseAdditive, binaryLevel{[]string{"<<", ">>"}, func(op string, a, b int64) int64 {
+		if b < 0 || b >= 64 {
+			return 0
+		}
+		if op == "<<" {
+			return a << uint(b)
+		}
+		return a >> uint(b)
+	}})
+}
+
+func (c *condParser) parseAdditive() core.Value {
+	left := c.parseMultiplicative()
+	for {
+		op := ""
+		if t, ok := c.peek(); ok && (isSymbol(t, "+") || isSymbol(t, "-")) {
+			op = t.Span.Text()
+		}
+		if op == "" {
+			return left
+		}
+		c.pos++
+		right := c.parseMultiplicative()
+		if op == "+" {
+			left = c.newInt(left.AsInt64() + right.AsInt64())
+		} else {
+			left = c.newInt(left.AsInt64() - right.AsInt64())
+		}
+	}
+}
+
+func (c *condParser) parseMultiplicative() core.Value {
+	left := c.parseUnary()
+	for {
+		op := ""
+		for _, cand := range []string{"*", "/", "%"} {
+			if t, ok := c.peek(); ok && isSymbol(t, cand) {
+				op = cand
+				break
+			}
+		}
+		if op == "" {
+			return left
+		}
+		c.pos++
+		right := c.parseUnary()
+		a, b := left.AsInt64(), right.AsInt64()
+		if (op == "/" || op == "%") && b == 0 {
+			return c.fail("division by zero in #if expression")
+		}
+		switch op {
+		case "*":
+			left = c.newInt(a * b)
+		case "/":
+			left = c.newInt(a / b)
+		case "%":
+			left = c.newInt(a % b)
+		}
+	}
+}
+
+func (c *condParser) parseUnary() core.Value {
+	if t, ok := c.peek(); ok {
+		switch {
+		case isSymbol(t, "!"):
+			c.pos++
+			return c.newInt(boolInt(c.parseUnary().AsInt64() == 0))
+		case isSymbol(t, "~"):
+			c.pos++
+			return c.newInt(^c.parseUnary().AsInt64())
+		case isSymbol(t, "-"):
+			c.pos++
+			return c.newInt(-c.parseUnary().AsInt64())
+		case isSymbol(t, "+"):
+			c.pos++
+			return c.parseUnary()
+		}
+	}
+	return c.parsePrimary()
+}
+
+func (c *condParser) parsePrimary() core.Value {
+	t, ok := c.peek()
+	if !ok {
+		return c.fail("unexpected end of #if expression")
+	}
+
+	if isSymbol(t, "(") {
+		c.pos++
+		val := c.parseExpr()
+		if !c.acceptSymbol(")") {
+			return c.fail("expected `)`")
+		}
+		return val
+	}
+
+	switch t.Kind {
+	case lexer.TokenNumber:
+		c.pos++
+		n, err := parseIntLiteral(t.Span.Text())
+		if err != nil {
+			return c.fail("invalid integer literal `%s`", t.Span.Text())
+		}
+		return c.newInt(n)
+	case lexer.TokenLiteral:
+		c.pos++
+		n, ok := parseCharLiteral(t.Span.Text())
+		if !ok {
+			return c.fail("invalid character literal `%s`", t.Span.Text())
+		}
+		return c.newInt(n)
+	case lexer.TokenWord:
+		// any identifier still standing after macro expansion (including
+		// keywords like `sizeof` this evaluator doesn't understand) is not
+		// an integer constant and evaluates to 0, per the C standard.
+		c.pos++
+		return c.newInt(0)
+	}
+
+	return c.fail("unexpected token `%s` in #if expression", t.Span.Text())
+}
+
+func parseIntLiteral(text string) (int64, error) {
+	text = strings.ReplaceAll(text, "_", "")
+	end := len(text)
+	for end > 0 {
+		switch text[end-1] {
+		case 'l', 'L', 'u', 'U':
+			end--
+			continue
+		}
+		break
+	}
+	return strconv.ParseInt(text[:end], 0, 64)
+}
+
+func parseCharLiteral(text string) (int64, bool) {
+	if len(text) < 3 || text[0] != '\'' || text[len(text)-1] != '\'' {
+		return 0, false
+	}
+	body := text[1 : len(text)-1]
+	if len(body) == 0 {
+		return 0, false
+	}
+	if body[0] == '\\' && len(body) > 1 {
+		switch body[1] {
+		case 'n':
+			return int64('\n'), true
+		case 't':
+			return int64('\t'), true
+		case 'r':
+			return int64('\r'), true
+		case '0':
+			return 0, true
+		default:
+			return int64(body[1]), true
+		}
+	}
+	for _, r := range body {
+		return int64(r), true
+	}
+	return 0, false
+}
@@ -0,0 +1,271 @@
+package preproc
+
+import (
+	"fmt"
+
+	"axlab.dev/byte/pkg/lexer"
+)
+
+var vaArgsName = "__VA_ARGS__"
+
+// expand macro-replaces toks, honoring hideset -- the set of macro names
+// currently being expanded higher up the call stack, so a macro can't
+// recursively expand itself (the "blue paint" rule).
+func (p *Preprocessor) expand(toks []lexer.Token, hideset map[string]bool) []lexer.Token {
+	var out []lexer.Token
+	for i := 0; i < len(toks); i++ {
+		tok := toks[i]
+		if tok.Kind != lexer.TokenWord {
+			out = append(out, tok)
+			continue
+		}
+
+		name := tok.Span.Text()
+
+		if name == "_Pragma" {
+			if args, next, ok := p.gatherArgs(toks, i+1); ok && len(args) > 0 {
+				p.Pragmas = append(p.Pragmas, Pragma{Text: destringize(spellOut(args[0])), At: tok.Span})
+				i = next - 1
+				continue
+			}
+		}
+
+		if hideset[name] {
+			out = append(out, tok)
+			continue
+		}
+
+		m, ok := p.macros[name]
+		if !ok {
+			out = append(out, tok)
+			continue
+		}
+
+		if !m.FuncLike {
+			body := p.pastePass(p.rebaseSpans(m.Body, tok.Span), tok.Span)
+			out = append(out, p.expand(body, addHide(hideset, name))...)
+			continue
+		}
+
+		args, next, ok := p.gatherArgs(toks, i+1)
+		if !ok {
+			out = append(out, tok) // not followed by `(`: leave the name as-is
+			continue
+		}
+
+		body := p.substParams(m, args, tok.Span)
+		out = append(out, p.expand(body, addHide(hideset, name))...)
+		i = next - 1
+	}
+	return out
+}
+
+func addHide(hideset map[string]bool, name string) map[string]bool {
+	out := make(map[string]bool, len(hideset)+1)
+	for k, v := range hideset {
+		out[k] = v
+	}
+	out[name] = true
+	return out
+}
+
+// gatherArgs parses a parenthesized, comma-separated argument list starting
+// at toks[i] (which must be `(`, modulo leading comments), returning the
+// per-argument token slices and the index just past the matching `)`. It
+// reports ok=false if toks[i] isn't `(` or the list runs off the end
+// unterminated.
+func (p *Preprocessor) gatherArgs(toks []lexer.Token, i int) (args [][]lexer.Token, next int, ok bool) {
+	for i < len(toks) && toks[i].Kind == lexer.TokenComment {
+		i++
+	}
+	if i >= len(toks) || !isSymbol(toks[i], "(") {
+		return nil, i, false
+	}
+	i++
+
+	depth := 1
+	var cur []lexer.Token
+	for i < len(toks) {
+		t := toks[i]
+		switch {
+		case t.Kind == lexer.TokenComment:
+			i++
+		case isSymbol(t, "("):
+			depth++
+			cur = append(cur, t)
+			i++
+		case isSymbol(t, ")"):
+			depth--
+			i++
+			if depth == 0 {
+				args = append(args, cur)
+				return args, i, true
+			}
+			cur = append(cur, t)
+		case depth == 1 && isSymbol(t, ","):
+			args = append(args, cur)
+			cur = nil
+			i++
+		default:
+			cur = append(cur, t)
+			i++
+		}
+	}
+
+	return nil, i, false
+}
+
+// substParams builds a function-like macro's replacement list for a single
+// call, substituting each parameter reference with its argument -- expanded,
+// unless the parameter is the operand of `#` (stringize) or adjacent to `##`
+// (paste), in which case the raw, as-written argument tokens are used.
+func (p *Preprocessor) substParams(m *Macro, args [][]lexer.Token, at lexer.Span) []lexer.Token {
+	argFor := func(name string) ([]lexer.Token, bool) {
+		for idx, pn := range m.Params {
+			if pn == name {
+				if idx < len(args) {
+					return args[idx], true
+				}
+				return nil, true
+			}
+		}
+		if m.Variadic && name == vaArgsName {
+			if len(args) > len(m.Params) {
+				return joinArgs(args[len(m.Params):]), true
+			}
+			return nil, true
+		}
+		return nil, false
+	}
+
+	body := m.Body
+	var out []lexer.Token
+	for i := 0; i < len(body); i++ {
+		t := body[i]
+
+		if isSymbol(t, "#") && i+1 < len(body) && body[i+1].Kind == lexer.TokenWord {
+			if raw, isParam := argFor(body[i+1].Span.Text()); isParam {
+				out = append(out, stringize(raw, at))
+				i++
+				continue
+			}
+		}
+
+		if t.Kind == lexer.TokenWord {
+			if raw, isParam := argFor(t.Span.Text()); isParam {
+				adjPaste := (i+1 < len(body) && isSymbol(body[i+1], "##")) ||
+					(i > 0 && isSymbol(body[i-1], "##"))
+
+				sub := raw
+				if !adjPaste {
+					sub = p.expand(raw, nil)
+				}
+				out = append(out, p.rebaseSpans(sub, at)...)
+				continue
+			}
+		}
+
+		out = append(out, p.rebaseSpans([]lexer.Token{t}, at)...)
+	}
+
+	return p.pastePass(out, at)
+}
+
+// joinArgs stitches the trailing variadic arguments back into a single
+// token list for __VA_ARGS__, re-inserting the commas that gatherArgs split
+// on.
+func joinArgs(args [][]lexer.Token) []lexer.Token {
+	var out []lexer.Token
+	for i, a := range args {
+		if i > 0 {
+			out = append(out, syntheticComma())
+		}
+		out = append(out, a...)
+	}
+	return out
+}
+
+// pastePass resolves every `##` in toks left to right, combining its two
+// neighbors into a single retokenized token.
+func (p *Preprocessor) pastePass(toks []lexer.Token, at lexer.Span) []lexer.Token {
+	var out []lexer.Token
+	for i := 0; i < len(toks); i++ {
+		if isSymbol(toks[i], "##") && len(out) > 0 && i+1 < len(toks) {
+			out[len(out)-1] = p.pasteTokens(out[len(out)-1], toks[i+1], at)
+			i++
+			continue
+		}
+		out = append(out, toks[i])
+	}
+	return out
+}
+
+func (p *Preprocessor) pasteTokens(left, right lexer.Token, at lexer.Span) lexer.Token {
+	pasted := p.rawTokenize(left.Span.Text() + right.Span.Text())
+	if len(pasted) != 1 {
+		p.errorAt(at, fmt.Sprintf("`##`: pasting `%s` and `%s` does not form a valid token", left.Span.Text(), right.Span.Text()))
+		return left
+	}
+
+	tok := pasted[0]
+	origin := at
+	tok.Span.ExpandedFrom = &origin
+	return tok
+}
+
+// rebaseSpans returns a copy of toks with ExpandedFrom pointing at from, so
+// a diagnostic raised against an expanded token can walk back to the
+// invocation that produced it.
+func (p *Preprocessor) rebaseSpans(toks []lexer.Token, from lexer.Span) []lexer.Token {
+	out := make([]lexer.Token, len(toks))
+	for i, t := range toks {
+		origin := from
+		t.Span.ExpandedFrom = &origin
+		out[i] = t
+	}
+	return out
+}
+
+func stringize(raw []lexer.Token, at lexer.Span) lexer.Token {
+	text := spellOut(raw)
+	quoted := `"`
+	for _, chr := range text {
+		if chr == '"' || chr == '\\' {
+			quoted += `\`
+		}
+		quoted += string(chr)
+	}
+	quoted += `"`
+
+	origin := at
+	src := &lexer.Source{Name: "<stringize>", Text: quoted}
+	return lexer.Token{
+		Kind: lexer.TokenLiteral,
+		Span: lexer.Span{Src: src, Sta: 0, End: len(quoted), Row: 1, Col: 1, Ind: 1, ExpandedFrom: &origin},
+	}
+}
+
+// destringize unwraps the string literal `_Pragma` is called with into the
+// raw pragma text it names.
+func destringize(text string) string {
+	if len(text) >= 2 && text[0] == '"' && text[len(text)-1] == '"' {
+		text = text[1 : len(text)-1]
+	}
+	out := make([]byte, 0, len(text))
+	for i := 0; i < len(text); i++ {
+		if text[i] == '\\' && i+1 < len(text) && (text[i+1] == '"' || text[i+1] == '\\') {
+			i++
+		}
+		out = append(out, text[i])
+	}
+	return string(out)
+}
+
+var syntheticCommaSrc = &lexer.Source{Name: "<macro>", Text: ","}
+
+func syntheticComma() lexer.Token {
+	return lexer.Token{
+		Kind: lexer.TokenSymbol,
+		Span: lexer.Span{Src: syntheticCommaSrc, Sta: 0, End: 1, Row: 1, Col: 1, Ind: 1},
+	}
+}
@@ -0,0 +1,270 @@
+// Package preproc implements a C-style preprocessor that runs between
+// lexer.Source loading and Lexer.Tokenize, modeled on the directive handling
+// in modernc.org/cc's scanner: #define/#undef, the #if/#ifdef/#ifndef/#elif
+// /#else/#endif conditional stack, #include/#include_next, #line, #error and
+// #pragma/_Pragma. It is opt-in -- set Preprocessor on the Lexer.Preprocessor
+// field and a lexer that never configures one is unaffected.
+package preproc
+
+import (
+	"fmt"
+	"strings"
+
+	"axlab.dev/byte/pkg/core"
+	"axlab.dev/byte/pkg/lexer"
+)
+
+// Macro is a single #define'd replacement: object-like when FuncLike is
+// false, otherwise function-like with Params holding the parameter names in
+// declaration order (Variadic means trailing arguments collect into
+// __VA_ARGS__).
+type Macro struct {
+	Name     string
+	FuncLike bool
+	Params   []string
+	Variadic bool
+	Body     []lexer.Token
+}
+
+// IncludeResolver loads the source for a #include (or, when next is true, a
+// #include_next) directive naming `name` -- the text between the quotes or
+// angle brackets, with quoted reporting which form was used -- as seen from
+// the including source `from`.
+type IncludeResolver func(name string, quoted bool, next bool, from *lexer.Source) (*lexer.Source, error)
+
+// Error is a diagnostic raised by #error or by a malformed directive.
+type Error struct {
+	Msg string
+	At  lexer.Span
+}
+
+func (e Error) String() string {
+	if e.At.IsZero() {
+		return e.Msg
+	}
+	return fmt.Sprintf("%s: %s", e.At.Location(), e.Msg)
+}
+
+// Pragma is a #pragma (or _Pragma(...)) directive collected during Process.
+// This package only records them; acting on one is up to the caller.
+type Pragma struct {
+	Text string
+	At   lexer.Span
+}
+
+// LineMark records a #line directive's requested line number/filename.
+// Diagnostics raised by this package still report real source positions --
+// remapping them to match is left to a downstream consumer.
+type LineMark struct {
+	Number int
+	File   string
+	At     lexer.Span
+}
+
+// Preprocessor holds the macro table and conditional-group stack for a
+// single compile. It is not safe for concurrent use.
+type Preprocessor struct {
+	Errors  []Error
+	Pragmas []Pragma
+	Lines   []LineMark
+
+	types   *core.TypeMap
+	lex     *lexer.Lexer
+	resolve IncludeResolver
+	macros  map[string]*Macro
+	cond    []condFrame
+}
+
+// condFrame tracks one #if/#elif/#else/#endif group on the conditional
+// stack. parentActive is whether the enclosing group was emitting when this
+// group was opened; taken records whether some branch in the group (the
+// current one included) has already matched, so a later #elif/#else is
+// skipped even if its own condition would hold.
+type condFrame struct {
+	parentActive bool
+	taken        bool
+	active       bool
+	sawElse      bool
+	at           lexer.Span
+}
+
+// New creates a Preprocessor that tokenizes macro bodies, pasted tokens and
+// #include'd sources with lex -- so #include content is itself recursively
+// preprocessed as long as lex.Preprocessor is set to the returned instance
+// -- and evaluates #if/#elif constant expressions using types.
+func New(lex *lexer.Lexer, types *core.TypeMap) *Preprocessor {
+	return &Preprocessor{
+		types:  types,
+		lex:    lex,
+		macros: make(map[string]*Macro),
+	}
+}
+
+// SetIncludeResolver installs the callback used to resolve #include and
+// #include_next directives. Without one, those directives are reported as
+// errors.
+func (p *Preprocessor) SetIncludeResolver(resolve IncludeResolver) {
+	p.resolve = resolve
+}
+
+// Define registers an object-like macro equivalent to a `#define name body`
+// line, for predefining macros ahead of time (e.g. command-line `-DFOO=1`).
+func (p *Preprocessor) Define(name, body string) {
+	p.macros[name] = &Macro{Name: name, Body: p.rawTokenize(body)}
+}
+
+// Undef removes a macro definition, if any.
+func (p *Preprocessor) Undef(name string) {
+	delete(p.macros, name)
+}
+
+// IsDefined reports whether name is currently #define'd.
+func (p *Preprocessor) IsDefined(name string) bool {
+	_, ok := p.macros[name]
+	return ok
+}
+
+func (p *Preprocessor) active() bool {
+	if len(p.cond) == 0 {
+		return true
+	}
+	top := p.cond[len(p.cond)-1]
+	return top.parentActive && top.active
+}
+
+// Process implements lexer.Preprocessor: it scans src's raw token stream
+// line by line, dispatching `#`-led lines as directives and macro-expanding
+// everything that survives the current conditional-group stack.
+func (p *Preprocessor) Process(src *lexer.Source, toks []lexer.Token) (out []lexer.Token) {
+	// an #include'd source is processed inside its includer's call, so only
+	// the groups this source opened count as unterminated at its end
+	base := len(p.cond)
+	sta := 0
+	for i := 0; i <= len(toks); i++ {
+		if i < len(toks) && toks[i].Kind != lexer.TokenBreak {
+			continue
+		}
+
+		line := toks[sta:i]
+		if name, nameSpan, rest, ok := splitDirective(line); ok {
+			out = append(out, p.dispatch(src, name, nameSpan, rest)...)
+		} else if p.active() {
+			out = append(out, p.expand(line, nil)...)
+		}
+
+		if i < len(toks) {
+			out = append(out, toks[i])
+		}
+		sta = i + 1
+	}
+
+	if len(p.cond) > base {
+		p.errorAt(p.cond[len(p.cond)-1].at, "unterminated #if")
+	}
+	return out
+}
+
+// dispatch routes a single `#directive` line. The conditional-group
+// directives always run, even inside a currently-inactive group, since they
+// are what makes the group active again; every other directive is a no-op
+// unless the current group is active.
+func (p *Preprocessor) dispatch(src *lexer.Source, name string, nameSpan lexer.Span, rest []lexer.Token) []lexer.Token {
+	switch name {
+	case "":
+		// null directive (a bare `#` line)
+	case "if":
+		p.doIf(rest)
+	case "ifdef":
+		p.doIfdef(rest, false)
+	case "ifndef":
+		p.doIfdef(rest, true)
+	case "elif":
+		p.doElif(rest)
+	case "else":
+		p.doElse(rest)
+	case "endif":
+		p.doEndif(rest)
+	default:
+		if !p.active() {
+			return nil
+		}
+		switch name {
+		case "define":
+			p.doDefine(rest)
+		case "undef":
+			p.doUndef(rest)
+		case "include":
+			return p.doInclude(src, rest, false)
+		case "include_next":
+			return p.doInclude(src, rest, true)
+		case "line":
+			p.doLine(rest)
+		case "error":
+			p.doError(rest)
+		case "pragma":
+			p.doPragma(rest)
+		default:
+			p.errorAt(nameSpan, fmt.Sprintf("unknown preprocessor directive `#%s`", name))
+		}
+	}
+	return nil
+}
+
+func (p *Preprocessor) errorAt(at lexer.Span, msg string) {
+	p.Errors = append(p.Errors, Error{Msg: msg, At: at})
+}
+
+// rawTokenize tokenizes text without running it back through this
+// Preprocessor, for the synthetic snippets built while pasting (`##`) and
+// expanding a token: the result should be a single fresh token, not the
+// already-expanded and directive-processed output that Tokenize would
+// otherwise feed it through (this instance is typically lex.Preprocessor).
+func (p *Preprocessor) rawTokenize(text string) []lexer.Token {
+	saved := p.lex.Preprocessor
+	p.lex.Preprocessor = nil
+	defer func() { p.lex.Preprocessor = saved }()
+
+	src := &lexer.Source{Name: "<preproc>", Text: text}
+	toks := p.lex.Tokenize(src)
+
+	out := toks[:0]
+	for _, t := range toks {
+		if t.Kind != lexer.TokenBreak {
+			out = append(out, t)
+		}
+	}
+	return out
+}
+
+func isSymbol(tok lexer.Token, text string) bool {
+	return tok.Kind == lexer.TokenSymbol && tok.Span.Text() == text
+}
+
+func skipComments(toks []lexer.Token) []lexer.Token {
+	out := make([]lexer.Token, 0, len(toks))
+	for _, t := range toks {
+		if t.Kind != lexer.TokenComment {
+			out = append(out, t)
+		}
+	}
+	return out
+}
+
+func spellOut(toks []lexer.Token) string {
+	parts := make([]string, 0, len(toks))
+	for _, t := range toks {
+		if t.Kind != lexer.TokenComment {
+			parts = append(parts, t.Span.Text())
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+func tokensSpanOr(toks []lexer.Token, fallback lexer.Span) lexer.Span {
+	for _, t := range toks {
+		if t.Kind != lexer.TokenComment {
+			return t.Span
+		}
+	}
+	return fallback
+}
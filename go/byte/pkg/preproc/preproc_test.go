@@ -0,0 +1,186 @@
+package preproc
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"axlab.dev/byte/pkg/core"
+	"axlab.dev/byte/pkg/lexer"
+)
+
+func newTestLexer() *lexer.Lexer {
+	lex := lexer.New()
+	lex.Comments = []string{"//"}
+	lex.AddSymbols("(", ")", ",", "#", "##", "...", "<", ">")
+	lex.AddSymbols("||", "&&", "==", "!=", "<=", ">=", "<<", ">>")
+	lex.AddSymbols("!", "~", "+", "-", "*", "/", "%", "|", "^", "&", "?", ":")
+	lex.MatchNumbers()
+	lex.MatchQuotedString(`"`, true, `\`)
+	lex.MatchQuotedString(`'`, true, `\`)
+	return lex
+}
+
+func run(t *testing.T, pp *Preprocessor, lex *lexer.Lexer, text string) []string {
+	src := &lexer.Source{Name: "test", Text: text}
+	var out []string
+	for _, tok := range lex.Tokenize(src) {
+		if tok.Kind == lexer.TokenBreak {
+			continue
+		}
+		out = append(out, tok.Span.Text())
+	}
+	require.Empty(t, pp.Errors, fmt.Sprintf("%+v", pp.Errors))
+	return out
+}
+
+func TestObjectLikeMacro(t *testing.T) {
+	lex := newTestLexer()
+	pp := New(lex, &core.TypeMap{})
+	lex.Preprocessor = pp
+
+	out := run(t, pp, lex, "#define WIDTH 80\nWIDTH + 1\n")
+	require.Equal(t, []string{"80", "+", "1"}, out)
+}
+
+func TestUndef(t *testing.T) {
+	lex := newTestLexer()
+	pp := New(lex, &core.TypeMap{})
+	lex.Preprocessor = pp
+
+	out := run(t, pp, lex, "#define X 1\n#undef X\nX\n")
+	require.Equal(t, []string{"X"}, out)
+}
+
+func TestFunctionLikeMacroWithStringizeAndPaste(t *testing.T) {
+	lex := newTestLexer()
+	pp := New(lex, &core.TypeMap{})
+	lex.Preprocessor = pp
+
+	out := run(t, pp, lex, "#define CAT(a, b) a ## b\n#define STR(x) #x\nCAT(foo, bar)\nSTR(hello)\n")
+	require.Equal(t, []string{"foobar", `"hello"`}, out)
+}
+
+func TestVariadicMacro(t *testing.T) {
+	lex := newTestLexer()
+	pp := New(lex, &core.TypeMap{})
+	lex.Preprocessor = pp
+
+	out := run(t, pp, lex, "#define LOG(fmt, ...) f(fmt, __VA_ARGS__)\nLOG(\"x\", 1, 2)\n")
+	require.Equal(t, []string{"f", "(", `"x"`, ",", "1", ",", "2", ")"}, out)
+}
+
+func TestSelfReferenceDoesNotRecurse(t *testing.T) {
+	lex := newTestLexer()
+	pp := New(lex, &core.TypeMap{})
+	lex.Preprocessor = pp
+
+	out := run(t, pp, lex, "#define X X + 1\nX\n")
+	require.Equal(t, []string{"X", "+", "1"}, out)
+}
+
+func TestConditionalGroups(t *testing.T) {
+	lex := newTestLexer()
+	pp := New(lex, &core.TypeMap{})
+	lex.Preprocessor = pp
+
+	text := "#define VER 2\n" +
+		"#if VER == 1\n" +
+		"one\n" +
+		"#elif VER == 2\n" +
+		"two\n" +
+		"#else\n" +
+		"other\n" +
+		"#endif\n"
+	out := run(t, pp, lex, text)
+	require.Equal(t, []string{"two"}, out)
+}
+
+func TestIfdefIfndef(t *testing.T) {
+	lex := newTestLexer()
+	pp := New(lex, &core.TypeMap{})
+	lex.Preprocessor = pp
+
+	text := "#define FOO\n" +
+		"#ifdef FOO\nyes\n#endif\n" +
+		"#ifndef FOO\nno\n#endif\n" +
+		"#ifndef BAR\nbar_undefined\n#endif\n"
+	out := run(t, pp, lex, text)
+	require.Equal(t, []string{"yes", "bar_undefined"}, out)
+}
+
+func TestDefinedOperator(t *testing.T) {
+	lex := newTestLexer()
+	pp := New(lex, &core.TypeMap{})
+	lex.Preprocessor = pp
+
+	text := "#define FOO\n#if defined(FOO) && !defined(BAR)\nmatched\n#endif\n"
+	out := run(t, pp, lex, text)
+	require.Equal(t, []string{"matched"}, out)
+}
+
+func TestArithmeticExpression(t *testing.T) {
+	lex := newTestLexer()
+	pp := New(lex, &core.TypeMap{})
+	lex.Preprocessor = pp
+
+	text := "#if (1 + 2 * 3) == 7 && (1 << 4) == 16\nok\n#endif\n"
+	out := run(t, pp, lex, text)
+	require.Equal(t, []string{"ok"}, out)
+}
+
+func TestPragmaOperator(t *testing.T) {
+	lex := newTestLexer()
+	pp := New(lex, &core.TypeMap{})
+	lex.Preprocessor = pp
+
+	out := run(t, pp, lex, `_Pragma("once") kept`+"\n")
+	require.Equal(t, []string{"kept"}, out)
+	require.Equal(t, []Pragma{{Text: "once", At: pp.Pragmas[0].At}}, pp.Pragmas)
+}
+
+func TestInclude(t *testing.T) {
+	lex := newTestLexer()
+	pp := New(lex, &core.TypeMap{})
+	lex.Preprocessor = pp
+
+	header := &lexer.Source{Name: "header.h", Text: "#define GREETING hi\n"}
+	pp.SetIncludeResolver(func(name string, quoted bool, next bool, from *lexer.Source) (*lexer.Source, error) {
+		require.Equal(t, "header.h", name)
+		require.True(t, quoted)
+		return header, nil
+	})
+
+	out := run(t, pp, lex, "#include \"header.h\"\nGREETING\n")
+	require.Equal(t, []string{"hi"}, out)
+}
+
+func TestErrorDirectiveIsRecorded(t *testing.T) {
+	lex := newTestLexer()
+	pp := New(lex, &core.TypeMap{})
+	lex.Preprocessor = pp
+
+	src := &lexer.Source{Name: "test", Text: "#error boom\n"}
+	lex.Tokenize(src)
+	require.Len(t, pp.Errors, 1)
+	require.Contains(t, pp.Errors[0].Msg, "boom")
+}
+
+func TestExpandedFromChain(t *testing.T) {
+	lex := newTestLexer()
+	pp := New(lex, &core.TypeMap{})
+	lex.Preprocessor = pp
+
+	src := &lexer.Source{Name: "test", Text: "#define X 1 + 2\nX\n"}
+	var toks []lexer.Token
+	for _, tok := range lex.Tokenize(src) {
+		if tok.Kind != lexer.TokenBreak {
+			toks = append(toks, tok)
+		}
+	}
+
+	require.NotEmpty(t, toks)
+	require.NotNil(t, toks[0].Span.ExpandedFrom)
+	require.Equal(t, "X", toks[0].Span.ExpandedFrom.Text())
+}
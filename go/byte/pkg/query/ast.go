@@ -0,0 +1,83 @@
+package query
+
+// expr is a compiled node in the query DSL's AST. Each implementation knows
+// how to evaluate itself against a single *nodes.Node -- there is no
+// intermediate bytecode, just a direct tree walk, since query expressions
+// are small and evaluated once per candidate node.
+type expr interface {
+	eval(ctx *evalCtx) any
+}
+
+// litExpr is a literal string, int64 or bool constant.
+type litExpr struct {
+	val any
+}
+
+func (e *litExpr) eval(ctx *evalCtx) any {
+	return e.val
+}
+
+// accessExpr reads a (possibly chained) field off the current node, e.g.
+// `kind`, `next.kind` or `type.name` -- see evalCtx.access for the supported
+// path segments.
+type accessExpr struct {
+	path []string
+}
+
+func (e *accessExpr) eval(ctx *evalCtx) any {
+	return ctx.access(e.path)
+}
+
+// notExpr negates a boolean operand.
+type notExpr struct {
+	x expr
+}
+
+func (e *notExpr) eval(ctx *evalCtx) any {
+	return !truthy(e.x.eval(ctx))
+}
+
+// binExpr is a binary operator: one of `&&`, `||`, `==`, `!=`, `<`, `<=`,
+// `>`, `>=`.
+type binExpr struct {
+	op   string
+	l, r expr
+}
+
+func (e *binExpr) eval(ctx *evalCtx) any {
+	switch e.op {
+	case "&&":
+		return truthy(e.l.eval(ctx)) && truthy(e.r.eval(ctx))
+	case "||":
+		return truthy(e.l.eval(ctx)) || truthy(e.r.eval(ctx))
+	}
+
+	l, r := e.l.eval(ctx), e.r.eval(ctx)
+	switch e.op {
+	case "==":
+		return equal(l, r)
+	case "!=":
+		return !equal(l, r)
+	default:
+		cmp, ok := compare(l, r)
+		if !ok {
+			return false
+		}
+		switch e.op {
+		case "<":
+			return cmp < 0
+		case "<=":
+			return cmp <= 0
+		case ">":
+			return cmp > 0
+		case ">=":
+			return cmp >= 0
+		}
+	}
+	return false
+}
+
+func truthy(v any) bool {
+	b, _ := v.(bool)
+	return b
+}
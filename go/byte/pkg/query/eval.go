@@ -0,0 +1,148 @@
+package query
+
+import (
+	"strings"
+
+	"axlab.dev/byte/pkg/core"
+	"axlab.dev/byte/pkg/nodes"
+)
+
+// evalCtx carries the node an expression is being evaluated against.
+type evalCtx struct {
+	node *nodes.Node
+}
+
+// access resolves a field path -- e.g. ["next", "kind"] -- against ctx.node,
+// hopping through next/prev/parent for every segment but the last. Returns
+// nil if the chain runs off the end of the list or the path names a field
+// that doesn't exist.
+//
+// NodeList is flat: nodes don't know their parent, so `parent` always hops
+// to nil. It's still accepted so `parent.kind == ...`-style queries parse;
+// they just never match.
+func (ctx *evalCtx) access(path []string) any {
+	cur := ctx.node
+	for i := 0; i < len(path); i++ {
+		if cur == nil {
+			return nil
+		}
+
+		seg := path[i]
+		switch seg {
+		case "next":
+			cur = cur.Next()
+		case "prev":
+			cur = cur.Prev()
+		case "parent":
+			cur = nil
+		case "kind":
+			return cur.Value().Type().Name()
+		case "text":
+			return cur.Value().String()
+		case "key":
+			return cur.Key()
+		case "type":
+			if i+1 < len(path) && path[i+1] == "name" {
+				return cur.Value().Type().Name()
+			}
+			return cur.Value().Type().String()
+		case "span":
+			if i+1 < len(path) && path[i+1] == "row" {
+				return int64(cur.Span().Row)
+			}
+			return cur.Span().String()
+		default:
+			return nil
+		}
+	}
+	return nil
+}
+
+// equal implements `==`/`!=` between any two values a query expression can
+// produce: string, int64 and bool literals, and core.Value from `key`.
+func equal(a, b any) bool {
+	cmp, ok := compare(a, b)
+	return ok && cmp == 0
+}
+
+// compare implements `<`, `<=`, `>`, `>=` (and backs equal for `==`/`!=`).
+// Comparing two core.Value (e.g. two `key` accesses) goes through
+// core.Value.Compare -- the CanCompare trait -- falling back to comparing
+// their displayed text (CanDisplay, via Value.String) when the types involved
+// have no registered comparator. Comparing a core.Value against a literal
+// compares its displayed text or AsInt64, depending on the literal's type.
+func compare(a, b any) (cmp int, ok bool) {
+	av, aIsVal := a.(core.Value)
+	bv, bIsVal := b.(core.Value)
+	switch {
+	case aIsVal && bIsVal:
+		return compareValues(av, bv), true
+	case aIsVal:
+		return compareValueToNative(av, b)
+	case bIsVal:
+		c, ok := compareValueToNative(bv, a)
+		return -c, ok
+	default:
+		return compareNative(a, b)
+	}
+}
+
+func compareValues(a, b core.Value) int {
+	if cmp, ok := safeCompare(a, b); ok {
+		return cmp
+	}
+	return strings.Compare(a.String(), b.String())
+}
+
+// safeCompare calls core.Value.Compare, which panics when no comparator is
+// registered between the two values' types -- that panic just means "fall
+// back to comparing text", not a bug, so it's recovered rather than
+// propagated.
+func safeCompare(a, b core.Value) (cmp int, ok bool) {
+	defer func() {
+		if recover() != nil {
+			cmp, ok = 0, false
+		}
+	}()
+	return a.Compare(b), true
+}
+
+func compareValueToNative(v core.Value, b any) (int, bool) {
+	switch bv := b.(type) {
+	case string:
+		return strings.Compare(v.String(), bv), true
+	case int64:
+		return compareInt64(v.AsInt64(), bv), true
+	default:
+		return 0, false
+	}
+}
+
+func compareNative(a, b any) (int, bool) {
+	switch av := a.(type) {
+	case string:
+		if bv, ok := b.(string); ok {
+			return strings.Compare(av, bv), true
+		}
+	case int64:
+		if bv, ok := b.(int64); ok {
+			return compareInt64(av, bv), true
+		}
+	case bool:
+		if bv, ok := b.(bool); ok && av == bv {
+			return 0, true
+		}
+	}
+	return 0, false
+}
+
+func compareInt64(a, b int64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
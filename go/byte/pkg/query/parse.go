@@ -0,0 +1,237 @@
+package query
+
+import (
+	"fmt"
+	"strconv"
+
+	"axlab.dev/byte/pkg/lexer"
+)
+
+// newQueryLexer configures a Lexer for the query DSL: single-quoted string
+// literals, decimal integers, `.`-joined accessor paths (e.g. `next.kind`)
+// and the small set of comparison/logical operators the grammar supports.
+func newQueryLexer() *lexer.Lexer {
+	lex := lexer.New()
+	lex.AddSymbols("==", "!=", "&&", "||", "!", "<=", "<", ">=", ">", "(", ")", ".")
+	lex.MatchQuotedString(`'`, true, `\`)
+	lex.MatchNumbers()
+	return lex
+}
+
+// parser is a recursive-descent parser over the token stream produced by
+// newQueryLexer, built from lowest to highest precedence: `||`, then `&&`,
+// then equality, then relational, then unary `!`, then primary expressions
+// (literals, accessor paths, and parenthesized sub-expressions).
+type parser struct {
+	toks []lexer.Token
+	pos  int
+}
+
+func parse(src string) (expr, error) {
+	lex := newQueryLexer()
+	var toks []lexer.Token
+	for _, tok := range lex.Tokenize(&lexer.Source{Name: "query", Text: src}) {
+		switch tok.Kind {
+		case lexer.TokenBreak, lexer.TokenComment:
+			continue
+		case lexer.TokenInvalid:
+			return nil, fmt.Errorf("query: invalid token %q at %s", tok.Span.Text(), tok.Span.Location())
+		}
+		toks = append(toks, tok)
+	}
+
+	p := &parser{toks: toks}
+	out, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if !p.atEnd() {
+		return nil, fmt.Errorf("query: unexpected %q at %s", p.text(), p.location())
+	}
+	return out, nil
+}
+
+func (p *parser) atEnd() bool {
+	return p.pos >= len(p.toks)
+}
+
+func (p *parser) peek() lexer.Token {
+	if p.atEnd() {
+		return lexer.Token{}
+	}
+	return p.toks[p.pos]
+}
+
+func (p *parser) text() string {
+	tok := p.peek()
+	return tok.Span.Text()
+}
+
+// location describes where the next token (or, at end of input, the lack of
+// one) is, for error messages -- peek() returns a zero Token past the end,
+// whose zero Span can't be passed to Span.Location (it has no Source).
+func (p *parser) location() string {
+	if p.atEnd() {
+		return "end of input"
+	}
+	return p.peek().Span.Location()
+}
+
+// matchSymbol consumes the next token if it is a TokenSymbol with the given
+// text, and reports whether it did.
+func (p *parser) matchSymbol(text string) bool {
+	if !p.atEnd() && p.peek().Kind == lexer.TokenSymbol && p.text() == text {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *parser) parseOr() (expr, error) {
+	out, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.matchSymbol("||") {
+		rhs, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		out = &binExpr{op: "||", l: out, r: rhs}
+	}
+	return out, nil
+}
+
+func (p *parser) parseAnd() (expr, error) {
+	out, err := p.parseEquality()
+	if err != nil {
+		return nil, err
+	}
+	for p.matchSymbol("&&") {
+		rhs, err := p.parseEquality()
+		if err != nil {
+			return nil, err
+		}
+		out = &binExpr{op: "&&", l: out, r: rhs}
+	}
+	return out, nil
+}
+
+func (p *parser) parseEquality() (expr, error) {
+	out, err := p.parseRelational()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op := ""
+		switch {
+		case p.matchSymbol("=="):
+			op = "=="
+		case p.matchSymbol("!="):
+			op = "!="
+		default:
+			return out, nil
+		}
+		rhs, err := p.parseRelational()
+		if err != nil {
+			return nil, err
+		}
+		out = &binExpr{op: op, l: out, r: rhs}
+	}
+}
+
+func (p *parser) parseRelational() (expr, error) {
+	out, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op := ""
+		switch {
+		case p.matchSymbol("<="):
+			op = "<="
+		case p.matchSymbol(">="):
+			op = ">="
+		case p.matchSymbol("<"):
+			op = "<"
+		case p.matchSymbol(">"):
+			op = ">"
+		default:
+			return out, nil
+		}
+		rhs, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		out = &binExpr{op: op, l: out, r: rhs}
+	}
+}
+
+func (p *parser) parseUnary() (expr, error) {
+	if p.matchSymbol("!") {
+		x, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &notExpr{x: x}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *parser) parsePrimary() (expr, error) {
+	if p.atEnd() {
+		return nil, fmt.Errorf("query: unexpected end of input")
+	}
+
+	tok := p.peek()
+	switch tok.Kind {
+	case lexer.TokenSymbol:
+		if tok.Span.Text() == "(" {
+			p.pos++
+			out, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			if !p.matchSymbol(")") {
+				return nil, fmt.Errorf("query: expected `)` at %s", p.location())
+			}
+			return out, nil
+		}
+	case lexer.TokenLiteral:
+		p.pos++
+		text := tok.Span.Text()
+		return &litExpr{val: text[1 : len(text)-1]}, nil
+	case lexer.TokenNumber:
+		p.pos++
+		n, err := strconv.ParseInt(tok.Span.Text(), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("query: invalid number %q at %s", tok.Span.Text(), tok.Span.Location())
+		}
+		return &litExpr{val: n}, nil
+	case lexer.TokenWord:
+		switch tok.Span.Text() {
+		case "true":
+			p.pos++
+			return &litExpr{val: true}, nil
+		case "false":
+			p.pos++
+			return &litExpr{val: false}, nil
+		}
+		return p.parseAccessor()
+	}
+
+	return nil, fmt.Errorf("query: unexpected %q at %s", tok.Span.Text(), tok.Span.Location())
+}
+
+func (p *parser) parseAccessor() (expr, error) {
+	path := []string{p.text()}
+	p.pos++
+	for p.matchSymbol(".") {
+		if p.atEnd() || p.peek().Kind != lexer.TokenWord {
+			return nil, fmt.Errorf("query: expected field name after `.` at %s", p.location())
+		}
+		path = append(path, p.text())
+		p.pos++
+	}
+	return &accessExpr{path: path}, nil
+}
@@ -0,0 +1,105 @@
+// Package query implements a small expression language, modeled on
+// antonmedv/expr, for matching and rewriting nodes.Node values inside a
+// nodes.NodeList: `next.kind == '(' && !(prev.text == '.')` rather than an
+// ad-hoc index-based loop. A Query compiles once and can be matched against
+// many nodes without re-parsing. Matching is not allocation-free: each Match
+// allocates its evaluation context, and accessor results are boxed into
+// interface values, which can allocate as well.
+//
+// Queries see a node through a handful of typed accessors: kind (the node
+// Value's type name), text (its displayed text), key, type / type.name,
+// span.row, and next/prev, which hop to the neighboring node and can be
+// chained (next.kind, prev.next.text, ...). parent is accepted by the
+// grammar for forward compatibility but always misses: NodeList is flat and
+// nodes don't currently know their parent.
+package query
+
+import (
+	"axlab.dev/byte/pkg/nodes"
+)
+
+// Query is a compiled query DSL expression.
+type Query struct {
+	src  string
+	expr expr
+}
+
+// Compile parses src as a query expression.
+func Compile(src string) (*Query, error) {
+	out, err := parse(src)
+	if err != nil {
+		return nil, err
+	}
+	return &Query{src: src, expr: out}, nil
+}
+
+func (q *Query) String() string {
+	return q.src
+}
+
+// Match reports whether node satisfies the query.
+func (q *Query) Match(node *nodes.Node) bool {
+	return truthy(q.expr.eval(&evalCtx{node: node}))
+}
+
+// Find returns the first node in list matching src, compiling it first.
+func Find(list *nodes.NodeList, src string) (*nodes.Node, error) {
+	q, err := Compile(src)
+	if err != nil {
+		return nil, err
+	}
+	for i := 0; i < list.Len(); i++ {
+		if node := list.Get(i); q.Match(node) {
+			return node, nil
+		}
+	}
+	return nil, nil
+}
+
+// FindAll returns every node in list matching src, compiling it first.
+func FindAll(list *nodes.NodeList, src string) ([]*nodes.Node, error) {
+	q, err := Compile(src)
+	if err != nil {
+		return nil, err
+	}
+	var out []*nodes.Node
+	for i := 0; i < list.Len(); i++ {
+		if node := list.Get(i); q.Match(node) {
+			out = append(out, node)
+		}
+	}
+	return out, nil
+}
+
+// Rewrite replaces every node in list matching pattern with the nodes fn
+// returns for it (nil or empty removes the node). Matching runs against the
+// list's state before any rewrite is applied, so an earlier replacement
+// can't change whether a later node matches; replacements are then applied
+// back to front so each one's index is still valid when it's used.
+func Rewrite(list *nodes.NodeList, pattern string, fn func(*nodes.Node) []*nodes.Node) error {
+	q, err := Compile(pattern)
+	if err != nil {
+		return err
+	}
+
+	type hit struct {
+		index int
+		node  *nodes.Node
+	}
+	var hits []hit
+	for i := 0; i < list.Len(); i++ {
+		if node := list.Get(i); q.Match(node) {
+			hits = append(hits, hit{i, node})
+		}
+	}
+
+	for i := len(hits) - 1; i >= 0; i-- {
+		h := hits[i]
+		replacement := fn(h.node)
+		list.RemoveAt(h.index)
+		if len(replacement) > 0 {
+			list.Insert(h.index, replacement...)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+package query
+
+import (
+	"testing"
+
+	"axlab.dev/byte/pkg/core"
+	"axlab.dev/byte/pkg/lexer"
+	"axlab.dev/byte/pkg/nodes"
+	"github.com/stretchr/testify/require"
+)
+
+func buildList(types *core.TypeMap, words ...string) *nodes.NodeList {
+	list := &nodes.NodeList{}
+	for _, w := range words {
+		val := core.NewValue(types.Str(), w)
+		list.Add(nodes.NewNode(val, lexer.Span{}))
+	}
+	return list
+}
+
+func TestFindByKindAndText(t *testing.T) {
+	test := require.New(t)
+
+	types := &core.TypeMap{}
+	list := buildList(types, "a", "b", "c")
+
+	node, err := Find(list, "kind == 'String' && text == 'b'")
+	test.NoError(err)
+	test.NotNil(node)
+	test.Equal("b", node.Value().String())
+
+	node, err = Find(list, "text == 'nope'")
+	test.NoError(err)
+	test.Nil(node)
+}
+
+func TestFindChainsNextAndPrev(t *testing.T) {
+	test := require.New(t)
+
+	types := &core.TypeMap{}
+	list := buildList(types, "a", "b", "c")
+
+	node, err := Find(list, "next.text == 'b'")
+	test.NoError(err)
+	test.Equal("a", node.Value().String())
+
+	node, err = Find(list, "prev.text == 'a' && next.text == 'c'")
+	test.NoError(err)
+	test.Equal("b", node.Value().String())
+
+	// `c` has no next, so the chain misses rather than matching/panicking
+	node, err = Find(list, "text == 'c' && next.text == 'anything'")
+	test.NoError(err)
+	test.Nil(node)
+}
+
+func TestFindAllAndNot(t *testing.T) {
+	test := require.New(t)
+
+	types := &core.TypeMap{}
+	list := buildList(types, "a", "b", "c")
+
+	matches, err := FindAll(list, "!(text == 'b')")
+	test.NoError(err)
+	test.Len(matches, 2)
+	test.Equal("a", matches[0].Value().String())
+	test.Equal("c", matches[1].Value().String())
+}
+
+func TestRewriteReplacesMatches(t *testing.T) {
+	test := require.New(t)
+
+	types := &core.TypeMap{}
+	list := buildList(types, "a", "b", "c")
+
+	err := Rewrite(list, "text == 'b'", func(n *nodes.Node) []*nodes.Node {
+		return []*nodes.Node{
+			nodes.NewNode(core.NewValue(types.Str(), "b1"), lexer.Span{}),
+			nodes.NewNode(core.NewValue(types.Str(), "b2"), lexer.Span{}),
+		}
+	})
+	test.NoError(err)
+
+	var texts []string
+	for i := 0; i < list.Len(); i++ {
+		texts = append(texts, list.Get(i).Value().String())
+	}
+	test.Equal([]string{"a", "b1", "b2", "c"}, texts)
+}
+
+func TestCompileErrors(t *testing.T) {
+	test := require.New(t)
+
+	_, err := Compile("next.")
+	test.Error(err)
+
+	_, err = Compile("kind ==")
+	test.Error(err)
+
+	_, err = Compile("kind == 'a' )")
+	test.Error(err)
+}
@@ -0,0 +1,147 @@
+package types
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"axlab.dev/util"
+)
+
+// Backend selects the target language for Expr.Emit.
+type Backend int
+
+const (
+	BackendGo Backend = iota
+	BackendCpp
+)
+
+// EmitContext carries the state a single Emit pass threads through the Expr
+// tree: which Backend to lower to, the set of headers/imports the emitted
+// statements turned out to need, and which variable names have already been
+// declared (one flat set for the whole Emit pass, so a second Decl of the
+// same name emits a plain `=` instead of redeclaring it).
+type EmitContext struct {
+	Backend  Backend
+	Headers  map[string]bool
+	declared map[string]bool
+}
+
+func NewEmitContext(backend Backend) *EmitContext {
+	return &EmitContext{Backend: backend, Headers: map[string]bool{}}
+}
+
+// RequireHeader records that the emitted source needs the given C++
+// #include or Go import, deduplicated and sorted when Program.Emit renders
+// the final source.
+func (ctx *EmitContext) RequireHeader(header string) {
+	ctx.Headers[header] = true
+}
+
+// Declare records name as declared in the current scope, returning true the
+// first time it's seen so callers can tell a fresh declaration from a
+// reassignment.
+func (ctx *EmitContext) Declare(name string) bool {
+	if ctx.declared == nil {
+		ctx.declared = map[string]bool{}
+	}
+	if ctx.declared[name] {
+		return false
+	}
+	ctx.declared[name] = true
+	return true
+}
+
+// TypeName maps a Type to its spelling in the target Backend.
+func (ctx *EmitContext) TypeName(t Type) string {
+	switch ctx.Backend {
+	case BackendCpp:
+		switch t {
+		case TypeInt:
+			return "int64_t"
+		case TypeStr:
+			return "std::string"
+		case TypeBool:
+			return "bool"
+		case TypeUnit:
+			return "void"
+		default:
+			return "auto"
+		}
+	default:
+		switch t {
+		case TypeInt:
+			return "int64"
+		case TypeStr:
+			return "string"
+		case TypeBool:
+			return "bool"
+		case TypeUnit:
+			return ""
+		default:
+			return "any"
+		}
+	}
+}
+
+// terminate appends the Backend's statement terminator to code, if any --
+// Go statements don't need one, C++ statements do.
+func (ctx *EmitContext) terminate(code string) string {
+	if ctx.Backend == BackendCpp {
+		return code + ";"
+	}
+	return code
+}
+
+// Emit lowers the program's top-level code to Backend source, returning a
+// single compilable file: headers/imports first (only the ones statements
+// actually required, plus <cstdint>, which the C++ backend always includes),
+// then a main function wrapping the emitted statements.
+func (me *Program) Emit(backend Backend) (string, error) {
+	ctx := NewEmitContext(backend)
+
+	stmts := make([]string, 0, len(me.code))
+	for _, it := range me.code {
+		stmt, err := it.Emit(ctx)
+		if err != nil {
+			return "", err
+		}
+		if stmt == "" {
+			continue
+		}
+		stmts = append(stmts, stmt)
+	}
+	body := strings.Join(stmts, "\n")
+
+	headers := make([]string, 0, len(ctx.Headers))
+	for h := range ctx.Headers {
+		headers = append(headers, h)
+	}
+	sort.Strings(headers)
+
+	out := strings.Builder{}
+	switch backend {
+	case BackendCpp:
+		out.WriteString("#include <cstdint>\n")
+		for _, h := range headers {
+			fmt.Fprintf(&out, "#include %s\n", h)
+		}
+		out.WriteString("\nint main() {\n")
+		out.WriteString(util.Indent(body))
+		out.WriteString("\n    return 0;\n}\n")
+	default:
+		out.WriteString("package main\n\n")
+		if len(headers) > 0 {
+			out.WriteString("import (\n")
+			for _, h := range headers {
+				fmt.Fprintf(&out, "    %s\n", strconv.Quote(h))
+			}
+			out.WriteString(")\n\n")
+		}
+		out.WriteString("func main() {\n")
+		out.WriteString(util.Indent(body))
+		out.WriteString("\n}\n")
+	}
+	return out.String(), nil
+}
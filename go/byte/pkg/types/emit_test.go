@@ -0,0 +1,48 @@
+package types_test
+
+import (
+	"strings"
+	"testing"
+
+	"axlab.dev/byte/pkg/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProgramEmitGoRendersForEachAsNativeLoop(t *testing.T) {
+	test := require.New(t)
+
+	program := &types.Program{}
+	program.Add(&types.ForEach{
+		Name: "i",
+		From: &types.Range{Sta: types.IntLiteral(0), End: types.IntLiteral(3)},
+		Body: &types.Print{List: []types.Expr{types.Var("i")}},
+	})
+
+	out, err := program.Emit(types.BackendGo)
+	test.NoError(err)
+	test.Contains(out, "for i := 0; (i < 3); i = (i + 1) {")
+	test.Contains(out, `"fmt"`)
+}
+
+func TestProgramEmitCppRendersDeclAndPrint(t *testing.T) {
+	test := require.New(t)
+
+	program := &types.Program{}
+	program.Add(&types.Decl{Name: "x", Value: types.IntLiteral(1)})
+	program.Add(&types.Print{List: []types.Expr{types.Var("x")}})
+
+	out, err := program.Emit(types.BackendCpp)
+	test.NoError(err)
+	test.Contains(out, "int64_t x = 1;")
+	test.Contains(out, "#include <iostream>")
+}
+
+func TestProgramEmitFailsForUnrepresentableRange(t *testing.T) {
+	test := require.New(t)
+
+	program := &types.Program{}
+	program.Add(&types.Range{Sta: types.IntLiteral(0), End: types.IntLiteral(3)})
+
+	_, err := program.Emit(types.BackendGo)
+	test.Error(err, "expected emitting a bare Range to fail")
+}
@@ -1,29 +1,38 @@
 package types
 
-import "fmt"
-
-var (
-	TypeUnit = Type("()")
-	TypeAny  = Type("???")
-	TypeInt  = Type("int")
-	TypeStr  = Type("str")
-	TypeBool = Type("bool")
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"axlab.dev/util"
 )
 
 type Program struct {
-	vars map[string]*Decl
-	code []Expr
+	vars     map[string]*Decl
+	types    map[string]*NamedType
+	generics map[string]*Generic
+	code     []Expr
+	callSeq  int
 }
 
 func (me *Program) Add(expr Expr) {
 	me.code = append(me.code, expr)
 }
 
-func (me *Program) Run() interface{} {
+// Run applies the fixed-point operator pass, compiles the resulting code
+// and executes it on a fresh Thread. A runtime error raised through
+// Thread.Abort comes back as err rather than unwinding the process. Panics
+// from the operator pass or from Compile (an undeclared name, an unknown
+// method, an unresolved Call, a failed operand type check) are not
+// recovered and unwind past Run, as does any other panic in an Exec closure.
+func (me *Program) Run() (result interface{}, err error) {
 	ops := []Operator{
 		OpForEach{},
 		OpDecl{},
 		OpBind{},
+		OpMethodCall{},
+		OpMonomorphize{},
 	}
 
 	for {
@@ -45,12 +54,13 @@ func (me *Program) Run() interface{} {
 		code = append(code, it.Compile(me))
 	}
 
-	var result interface{}
-	rt := &Runtime{}
-	for _, it := range code {
-		result = it(rt)
-	}
-	return result
+	thread := &Thread{}
+	err = thread.Try(func(th *Thread) {
+		for _, it := range code {
+			result = it(th)
+		}
+	})
+	return result, err
 }
 
 func (me *Program) applyOperator(op Operator) (out bool) {
@@ -93,13 +103,10 @@ type Expr interface {
 	Type() Type
 	Visit(fn func(*Expr))
 	Compile(program *Program) Exec
+	Emit(ctx *EmitContext) (string, error)
 }
 
-type Runtime struct {
-	vars map[string]interface{}
-}
-
-type Exec func(*Runtime) interface{}
+type Exec func(*Thread) interface{}
 
 type Iterable interface {
 	Start() Expr
@@ -109,10 +116,20 @@ type Iterable interface {
 
 type OpDecl struct{}
 
+// Apply registers a *Decl in program.vars, a *NamedType in program.types or
+// a *Generic in program.generics -- the declaration tables Ref/Var (OpBind),
+// OpMethodCall and OpMonomorphize resolve names against. A *Decl stays
+// unsolved (and so gets revisited every round) until its Value resolves, so
+// Apply must be idempotent for a node it already registered -- reporting no
+// change rather than re-panicking on its own prior registration -- or a
+// later operator like OpBind never gets a turn to solve Value.
 func (me OpDecl) Apply(program *Program, expr Expr) (out Expr, ok bool) {
-	decl, ok := expr.(*Decl)
-	if ok {
-		if _, ok := program.vars[decl.Name]; ok {
+	switch decl := expr.(type) {
+	case *Decl:
+		if existing, ok := program.vars[decl.Name]; ok {
+			if existing == decl {
+				return nil, false
+			}
 			panic(fmt.Sprintf("variable `%s` already declared", decl.Name))
 		}
 		if program.vars == nil {
@@ -120,24 +137,57 @@ func (me OpDecl) Apply(program *Program, expr Expr) (out Expr, ok bool) {
 		}
 		program.vars[decl.Name] = decl
 		return decl, true
+	case *NamedType:
+		if existing, ok := program.types[decl.Name]; ok {
+			if existing == decl {
+				return nil, false
+			}
+			panic(fmt.Sprintf("type `%s` already declared", decl.Name))
+		}
+		if program.types == nil {
+			program.types = make(map[string]*NamedType)
+		}
+		program.types[decl.Name] = decl
+		return decl, true
+	case *Generic:
+		if existing, ok := program.generics[decl.Name]; ok {
+			if existing == decl {
+				return nil, false
+			}
+			panic(fmt.Sprintf("generic `%s` already declared", decl.Name))
+		}
+		if program.generics == nil {
+			program.generics = make(map[string]*Generic)
+		}
+		program.generics[decl.Name] = decl
+		return decl, true
 	}
 	return nil, false
 }
 
 type OpBind struct{}
 
+// Apply resolves a Var by consulting program.vars first (producing a Ref),
+// then program.types (producing a TypeRef), then program.generics (producing
+// a GenericRef), so a bare name can equally well name a variable, a
+// `type T = ...` declaration or a generic function.
 func (me OpBind) Apply(program *Program, expr Expr) (out Expr, ok bool) {
 	aVar, ok := expr.(Var)
-	if ok {
-		name := string(aVar)
-		if decl, ok := program.vars[name]; !ok {
-			panic(fmt.Sprintf("variable `%s` is not declared", name))
-		} else {
-			ref := &Ref{Name: name, Target: decl}
-			return ref, true
-		}
+	if !ok {
+		return nil, false
 	}
-	return nil, false
+
+	name := string(aVar)
+	if decl, ok := program.vars[name]; ok {
+		return &Ref{Name: name, Target: decl}, true
+	}
+	if named, ok := program.types[name]; ok {
+		return &TypeRef{Name: name, Target: named}, true
+	}
+	if generic, ok := program.generics[name]; ok {
+		return &GenericRef{Name: name, Target: generic}, true
+	}
+	panic(fmt.Sprintf("`%s` is not declared", name))
 }
 
 type OpForEach struct{}
@@ -174,16 +224,6 @@ func (me OpForEach) Apply(program *Program, expr Expr) (out Expr, ok bool) {
 	return nil, false
 }
 
-type Type string
-
-func TypeOr(a, b Type) Type {
-	if a == b {
-		return a
-	} else {
-		return Type(fmt.Sprintf("%s|%s", a, b))
-	}
-}
-
 type StrLiteral string
 
 func (me StrLiteral) IsSolved(program *Program) bool {
@@ -197,11 +237,15 @@ func (me StrLiteral) Type() Type {
 func (me StrLiteral) Visit(fn func(*Expr)) {}
 
 func (me StrLiteral) Compile(program *Program) Exec {
-	return func(*Runtime) interface{} {
+	return func(*Thread) interface{} {
 		return string(me)
 	}
 }
 
+func (me StrLiteral) Emit(ctx *EmitContext) (string, error) {
+	return strconv.Quote(string(me)), nil
+}
+
 type IntLiteral int64
 
 func (me IntLiteral) IsSolved(program *Program) bool {
@@ -215,11 +259,15 @@ func (me IntLiteral) Type() Type {
 func (me IntLiteral) Visit(fn func(*Expr)) {}
 
 func (me IntLiteral) Compile(program *Program) Exec {
-	return func(*Runtime) interface{} {
+	return func(*Thread) interface{} {
 		return int64(me)
 	}
 }
 
+func (me IntLiteral) Emit(ctx *EmitContext) (string, error) {
+	return strconv.FormatInt(int64(me), 10), nil
+}
+
 type Var string
 
 func (me Var) IsSolved(program *Program) bool {
@@ -236,6 +284,10 @@ func (me Var) Compile(program *Program) Exec {
 	panic("unresolved variable cannot be compiled")
 }
 
+func (me Var) Emit(ctx *EmitContext) (string, error) {
+	return string(me), nil
+}
+
 type Ref struct {
 	Name   string
 	Target *Decl
@@ -252,7 +304,7 @@ func (me Ref) Type() Type {
 func (me Ref) Visit(fn func(*Expr)) {}
 
 func (me Ref) Compile(program *Program) Exec {
-	return func(rt *Runtime) interface{} {
+	return func(rt *Thread) interface{} {
 		if v, ok := rt.vars[me.Name]; ok {
 			return v
 		} else {
@@ -261,6 +313,10 @@ func (me Ref) Compile(program *Program) Exec {
 	}
 }
 
+func (me Ref) Emit(ctx *EmitContext) (string, error) {
+	return me.Name, nil
+}
+
 type Range struct {
 	Sta Expr
 	End Expr
@@ -283,6 +339,10 @@ func (me *Range) Compile(program *Program) Exec {
 	panic("range cannot be compiled")
 }
 
+func (me *Range) Emit(ctx *EmitContext) (string, error) {
+	return "", fmt.Errorf("range has no source-level representation outside of a ForEach")
+}
+
 func (me *Range) Start() Expr {
 	return me.Sta
 }
@@ -324,6 +384,42 @@ func (me *ForEach) Compile(program *Program) Exec {
 	panic("foreach cannot be compiled directly")
 }
 
+// Emit lowers the loop directly to the target's native `for`, using the same
+// Iterable methods OpForEach.Apply uses to desugar it for Compile -- Emit
+// runs on the Expr tree as written, so it gets a chance to do this before
+// OpForEach would otherwise unroll it into a Decl/While pair.
+func (me *ForEach) Emit(ctx *EmitContext) (string, error) {
+	from, ok := me.From.(Iterable)
+	if !ok {
+		return "", fmt.Errorf("foreach: %T is not Iterable", me.From)
+	}
+
+	start, err := from.Start().Emit(ctx)
+	if err != nil {
+		return "", err
+	}
+	cond, err := from.Cond(Var(me.Name)).Emit(ctx)
+	if err != nil {
+		return "", err
+	}
+	next, err := from.Next(Var(me.Name)).Emit(ctx)
+	if err != nil {
+		return "", err
+	}
+	body, err := me.Body.Emit(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	ctx.Declare(me.Name)
+	if ctx.Backend == BackendCpp {
+		return fmt.Sprintf("for (%s %s = %s; %s; %s = %s) {\n%s\n}",
+			ctx.TypeName(TypeInt), me.Name, start, cond, me.Name, next, util.Indent(body)), nil
+	}
+	return fmt.Sprintf("for %s := %s; %s; %s = %s {\n%s\n}",
+		me.Name, start, cond, me.Name, next, util.Indent(body)), nil
+}
+
 type Code struct {
 	Expr []Expr
 }
@@ -357,7 +453,7 @@ func (me *Code) Compile(program *Program) Exec {
 		exec = append(exec, it.Compile(program))
 	}
 
-	return func(rt *Runtime) interface{} {
+	return func(rt *Thread) interface{} {
 		var result interface{}
 		for _, it := range exec {
 			result = it(rt)
@@ -366,6 +462,20 @@ func (me *Code) Compile(program *Program) Exec {
 	}
 }
 
+func (me *Code) Emit(ctx *EmitContext) (string, error) {
+	stmts := make([]string, 0, len(me.Expr))
+	for _, it := range me.Expr {
+		stmt, err := it.Emit(ctx)
+		if err != nil {
+			return "", err
+		}
+		if stmt != "" {
+			stmts = append(stmts, stmt)
+		}
+	}
+	return strings.Join(stmts, "\n"), nil
+}
+
 type Print struct {
 	List []Expr
 }
@@ -395,7 +505,7 @@ func (me *Print) Compile(program *Program) Exec {
 		exec = append(exec, it.Compile(program))
 	}
 
-	return func(rt *Runtime) interface{} {
+	return func(rt *Thread) interface{} {
 		empty := true
 		for _, it := range exec {
 			value := it(rt)
@@ -412,6 +522,33 @@ func (me *Print) Compile(program *Program) Exec {
 	}
 }
 
+func (me *Print) Emit(ctx *EmitContext) (string, error) {
+	args := make([]string, 0, len(me.List))
+	for _, it := range me.List {
+		arg, err := it.Emit(ctx)
+		if err != nil {
+			return "", err
+		}
+		args = append(args, arg)
+	}
+
+	if ctx.Backend == BackendCpp {
+		ctx.RequireHeader("<iostream>")
+		parts := []string{"std::cout"}
+		for i, it := range args {
+			if i > 0 {
+				parts = append(parts, `<< " "`)
+			}
+			parts = append(parts, "<< "+it)
+		}
+		parts = append(parts, "<< std::endl;")
+		return strings.Join(parts, " "), nil
+	}
+
+	ctx.RequireHeader("fmt")
+	return fmt.Sprintf("fmt.Println(%s)", strings.Join(args, ", ")), nil
+}
+
 type Decl struct {
 	Name  string
 	Value Expr
@@ -432,7 +569,7 @@ func (me *Decl) Visit(fn func(*Expr)) {
 
 func (me *Decl) Compile(program *Program) Exec {
 	value := me.Value.Compile(program)
-	return func(rt *Runtime) interface{} {
+	return func(rt *Thread) interface{} {
 		result := value(rt)
 		if rt.vars == nil {
 			rt.vars = make(map[string]interface{})
@@ -442,6 +579,21 @@ func (me *Decl) Compile(program *Program) Exec {
 	}
 }
 
+func (me *Decl) Emit(ctx *EmitContext) (string, error) {
+	value, err := me.Value.Emit(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	if !ctx.Declare(me.Name) {
+		return ctx.terminate(fmt.Sprintf("%s = %s", me.Name, value)), nil
+	}
+	if ctx.Backend == BackendCpp {
+		return ctx.terminate(fmt.Sprintf("%s %s = %s", ctx.TypeName(me.Type()), me.Name, value)), nil
+	}
+	return fmt.Sprintf("%s := %s", me.Name, value), nil
+}
+
 type OpLess struct {
 	Lhs Expr
 	Rhs Expr
@@ -461,19 +613,37 @@ func (me *OpLess) Visit(fn func(*Expr)) {
 }
 
 func (me *OpLess) Compile(program *Program) Exec {
-	if me.Lhs.Type() != TypeInt || me.Rhs.Type() != TypeInt {
+	if !IsIdentical(me.Lhs.Type(), TypeInt) || !IsIdentical(me.Rhs.Type(), TypeInt) {
 		panic("invalid less comparison")
 	}
 
 	lhs := me.Lhs.Compile(program)
 	rhs := me.Rhs.Compile(program)
-	return func(rt *Runtime) interface{} {
-		a := lhs(rt).(int64)
-		b := rhs(rt).(int64)
+	return func(rt *Thread) interface{} {
+		a, ok := lhs(rt).(int64)
+		if !ok {
+			rt.Abort(&TypeAssertError{Op: "<", Expected: TypeInt})
+		}
+		b, ok := rhs(rt).(int64)
+		if !ok {
+			rt.Abort(&TypeAssertError{Op: "<", Expected: TypeInt})
+		}
 		return a < b
 	}
 }
 
+func (me *OpLess) Emit(ctx *EmitContext) (string, error) {
+	lhs, err := me.Lhs.Emit(ctx)
+	if err != nil {
+		return "", err
+	}
+	rhs, err := me.Rhs.Emit(ctx)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("(%s < %s)", lhs, rhs), nil
+}
+
 type OpAdd struct {
 	Lhs Expr
 	Rhs Expr
@@ -493,19 +663,37 @@ func (me *OpAdd) Visit(fn func(*Expr)) {
 }
 
 func (me *OpAdd) Compile(program *Program) Exec {
-	if me.Lhs.Type() != TypeInt || me.Rhs.Type() != TypeInt {
+	if !IsIdentical(me.Lhs.Type(), TypeInt) || !IsIdentical(me.Rhs.Type(), TypeInt) {
 		panic("invalid addition")
 	}
 
 	lhs := me.Lhs.Compile(program)
 	rhs := me.Rhs.Compile(program)
-	return func(rt *Runtime) interface{} {
-		a := lhs(rt).(int64)
-		b := rhs(rt).(int64)
+	return func(rt *Thread) interface{} {
+		a, ok := lhs(rt).(int64)
+		if !ok {
+			rt.Abort(&TypeAssertError{Op: "+", Expected: TypeInt})
+		}
+		b, ok := rhs(rt).(int64)
+		if !ok {
+			rt.Abort(&TypeAssertError{Op: "+", Expected: TypeInt})
+		}
 		return a + b
 	}
 }
 
+func (me *OpAdd) Emit(ctx *EmitContext) (string, error) {
+	lhs, err := me.Lhs.Emit(ctx)
+	if err != nil {
+		return "", err
+	}
+	rhs, err := me.Rhs.Emit(ctx)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("(%s + %s)", lhs, rhs), nil
+}
+
 type Set struct {
 	Name string
 	Expr Expr
@@ -526,12 +714,12 @@ func (me *Set) Visit(fn func(*Expr)) {
 func (me *Set) Compile(program *Program) Exec {
 	if decl, ok := program.vars[me.Name]; !ok {
 		panic(fmt.Sprintf("cannot set undeclared `%s`", me.Name))
-	} else if decl.Type() != me.Type() {
+	} else if !IsIdentical(decl.Type(), me.Type()) {
 		panic(fmt.Sprintf("cannot set %s to variable `%s` of type %s", me.Type(), me.Name, decl.Type()))
 	}
 
 	expr := me.Expr.Compile(program)
-	return func(rt *Runtime) interface{} {
+	return func(rt *Thread) interface{} {
 		value := expr(rt)
 		if rt.vars == nil {
 			rt.vars = make(map[string]interface{})
@@ -541,6 +729,14 @@ func (me *Set) Compile(program *Program) Exec {
 	}
 }
 
+func (me *Set) Emit(ctx *EmitContext) (string, error) {
+	value, err := me.Expr.Emit(ctx)
+	if err != nil {
+		return "", err
+	}
+	return ctx.terminate(fmt.Sprintf("%s = %s", me.Name, value)), nil
+}
+
 type While struct {
 	Cond Expr
 	Body Expr
@@ -563,7 +759,7 @@ func (me *While) Compile(program *Program) Exec {
 	cond := me.Cond.Compile(program)
 	body := me.Body.Compile(program)
 
-	check := func(rt *Runtime) bool {
+	check := func(rt *Thread) bool {
 		result := cond(rt)
 		if result == nil {
 			return false
@@ -580,7 +776,7 @@ func (me *While) Compile(program *Program) Exec {
 		}
 	}
 
-	return func(rt *Runtime) interface{} {
+	return func(rt *Thread) interface{} {
 		for check(rt) {
 			body(rt)
 		}
@@ -588,6 +784,22 @@ func (me *While) Compile(program *Program) Exec {
 	}
 }
 
+func (me *While) Emit(ctx *EmitContext) (string, error) {
+	cond, err := me.Cond.Emit(ctx)
+	if err != nil {
+		return "", err
+	}
+	body, err := me.Body.Emit(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	if ctx.Backend == BackendCpp {
+		return fmt.Sprintf("while (%s) {\n%s\n}", cond, util.Indent(body)), nil
+	}
+	return fmt.Sprintf("for %s {\n%s\n}", cond, util.Indent(body)), nil
+}
+
 //----------------------------------------------------------------------------//
 // Utilities
 //----------------------------------------------------------------------------//
@@ -602,6 +814,12 @@ var _ = func() {
 	assertExpr(IntLiteral(0))
 	assertExpr(StrLiteral(""))
 	assertExpr(Var(""))
+	assertExpr(&NamedType{})
+	assertExpr(TypeRef{})
+	assertExpr(&MethodCall{})
+	assertExpr(&Generic{})
+	assertExpr(GenericRef{})
+	assertExpr(&Call{})
 
 	assertIterator(&Range{})
 }
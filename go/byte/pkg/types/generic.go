@@ -0,0 +1,303 @@
+package types
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Generic declares a type-parametric function: TypeParams names the type
+// parameter each positional argument must have, so a repeated name (e.g.
+// ["T", "T"] for a two-argument function) constrains those positions to the
+// same concrete type at a given call site. Body refers to its arguments
+// positionally as Ref{Name: "arg0"}, Ref{Name: "arg1"}, ... the same way
+// Method.Body refers to "self" and "argN" -- OpMonomorphize binds those names
+// with a Decl per call site before running a specialized clone of Body.
+type Generic struct {
+	Name       string
+	TypeParams []string
+	Body       Expr
+}
+
+func (me *Generic) IsSolved(program *Program) bool {
+	decl, ok := program.generics[me.Name]
+	return ok && decl == me
+}
+
+func (me *Generic) Type() Type {
+	return TypeAny
+}
+
+func (me *Generic) Visit(fn func(*Expr)) {}
+
+func (me *Generic) Compile(program *Program) Exec {
+	return func(*Thread) interface{} {
+		return nil
+	}
+}
+
+func (me *Generic) Emit(ctx *EmitContext) (string, error) {
+	return "", fmt.Errorf("generic function `%s` has no emitted statement form yet", me.Name)
+}
+
+// GenericRef is what a Var naming a declared Generic resolves to via OpBind,
+// mirroring how a Var naming a NamedType resolves to a TypeRef.
+type GenericRef struct {
+	Name   string
+	Target *Generic
+}
+
+func (me GenericRef) IsSolved(program *Program) bool {
+	return true
+}
+
+func (me GenericRef) Type() Type {
+	return TypeAny
+}
+
+func (me GenericRef) Visit(fn func(*Expr)) {}
+
+func (me GenericRef) Compile(program *Program) Exec {
+	return func(*Thread) interface{} {
+		return nil
+	}
+}
+
+func (me GenericRef) Emit(ctx *EmitContext) (string, error) {
+	return me.Name, nil
+}
+
+// Call is the surface syntax for `Callee(TypeArgs...)(Args...)`, unsolved
+// until OpMonomorphize can resolve Callee against a declared Generic.
+type Call struct {
+	Callee   Expr
+	TypeArgs []Type
+	Args     []Expr
+}
+
+func (me *Call) IsSolved(program *Program) bool {
+	return false
+}
+
+func (me *Call) Type() Type {
+	return TypeAny
+}
+
+func (me *Call) Visit(fn func(*Expr)) {
+	fn(&me.Callee)
+	for i := range me.Args {
+		fn(&me.Args[i])
+	}
+}
+
+func (me *Call) Compile(program *Program) Exec {
+	panic("call was not resolved by OpMonomorphize")
+}
+
+func (me *Call) Emit(ctx *EmitContext) (string, error) {
+	return "", fmt.Errorf("call was not resolved by OpMonomorphize")
+}
+
+// OpMonomorphize rewrites a Call to a *GenericRef into a direct call once
+// every position's type argument -- explicit via TypeArgs, or else inferred
+// from Args[i].Type() -- is solved and every position sharing a type
+// parameter name agrees. A conflicting inference at two positions of the
+// same name leaves the Call unsolved rather than panicking, so the
+// fixed-point loop can retry after another operator refines one of the
+// Args. Each distinct concrete instantiation is specialized once and
+// interned under a mangled name in program.generics -- as a zero-parameter
+// Generic wrapping the substituted Body -- so a repeated instantiation
+// reuses that template instead of re-running substGenericTypes; each call
+// site still gets its own fresh argN bindings, the same way OpMethodCall
+// gives each call site its own self/argN.
+type OpMonomorphize struct{}
+
+func (me OpMonomorphize) Apply(program *Program, expr Expr) (out Expr, ok bool) {
+	call, ok := expr.(*Call)
+	if !ok {
+		return nil, false
+	}
+	ref, ok := call.Callee.(*GenericRef)
+	if !ok {
+		return nil, false
+	}
+
+	generic := ref.Target
+	arity := len(generic.TypeParams)
+	if len(call.Args) != arity {
+		panic(fmt.Sprintf("generic `%s` expects %d argument(s), got %d", generic.Name, arity, len(call.Args)))
+	}
+	if len(call.TypeArgs) != 0 && len(call.TypeArgs) != arity {
+		panic(fmt.Sprintf("generic `%s` expects %d type argument(s), got %d", generic.Name, arity, len(call.TypeArgs)))
+	}
+
+	position := make([]Type, arity)
+	for i := range position {
+		if len(call.TypeArgs) != 0 {
+			position[i] = call.TypeArgs[i]
+			continue
+		}
+		if !call.Args[i].IsSolved(program) {
+			return nil, false
+		}
+		position[i] = call.Args[i].Type()
+	}
+
+	subst := map[string]Type{}
+	order := make([]string, 0, arity)
+	for i, name := range generic.TypeParams {
+		if prev, ok := subst[name]; ok {
+			if !IsIdentical(prev, position[i]) {
+				return nil, false
+			}
+			continue
+		}
+		subst[name] = position[i]
+		order = append(order, name)
+	}
+
+	parts := make([]string, len(order))
+	for i, name := range order {
+		parts[i] = subst[name].String()
+	}
+	mangled := generic.Name + "$" + strings.Join(parts, "$")
+
+	instance, ok := program.generics[mangled]
+	if !ok {
+		instance = &Generic{Name: mangled, Body: substGenericTypes(generic.Body, subst)}
+		if program.generics == nil {
+			program.generics = make(map[string]*Generic)
+		}
+		program.generics[mangled] = instance
+	}
+
+	program.callSeq++
+	suffix := fmt.Sprintf("$%d", program.callSeq)
+
+	locals := make(map[string]*Decl, len(call.Args))
+	code := make([]Expr, 0, len(call.Args)+1)
+	for i, arg := range call.Args {
+		name := fmt.Sprintf("arg%d", i)
+		decl := &Decl{Name: name + suffix, Value: arg}
+		locals[name] = decl
+		code = append(code, decl)
+	}
+	code = append(code, renameBoundVars(instance.Body, suffix, locals))
+
+	return &Code{Expr: code}, true
+}
+
+// substGenericTypes clones expr, substituting any Type named by subst
+// wherever a Type value is embedded in the tree (a NamedType's Underlying,
+// or a nested Method's Receiver/Params/Results) -- the counterpart to
+// renameBoundVars, which rewrites bound names rather than Types.
+func substGenericTypes(expr Expr, subst map[string]Type) Expr {
+	switch node := expr.(type) {
+	case *NamedType:
+		methods := make(map[string]Method, len(node.Methods))
+		for name, m := range node.Methods {
+			params := make([]Type, len(m.Params))
+			for i, p := range m.Params {
+				params[i] = substType(p, subst)
+			}
+			results := make([]Type, len(m.Results))
+			for i, r := range m.Results {
+				results[i] = substType(r, subst)
+			}
+			methods[name] = Method{
+				Receiver: substType(m.Receiver, subst),
+				Params:   params,
+				Results:  results,
+				Body:     substGenericTypes(m.Body, subst),
+			}
+		}
+		return &NamedType{Name: node.Name, Underlying: substType(node.Underlying, subst), Methods: methods}
+	case *Code:
+		out := make([]Expr, len(node.Expr))
+		for i, it := range node.Expr {
+			out[i] = substGenericTypes(it, subst)
+		}
+		return &Code{Expr: out}
+	case *Print:
+		out := make([]Expr, len(node.List))
+		for i, it := range node.List {
+			out[i] = substGenericTypes(it, subst)
+		}
+		return &Print{List: out}
+	case *Decl:
+		return &Decl{Name: node.Name, Value: substGenericTypes(node.Value, subst)}
+	case *Set:
+		return &Set{Name: node.Name, Expr: substGenericTypes(node.Expr, subst)}
+	case *OpAdd:
+		return &OpAdd{Lhs: substGenericTypes(node.Lhs, subst), Rhs: substGenericTypes(node.Rhs, subst)}
+	case *OpLess:
+		return &OpLess{Lhs: substGenericTypes(node.Lhs, subst), Rhs: substGenericTypes(node.Rhs, subst)}
+	case *While:
+		return &While{Cond: substGenericTypes(node.Cond, subst), Body: substGenericTypes(node.Body, subst)}
+	case *ForEach:
+		return &ForEach{Name: node.Name, From: substGenericTypes(node.From, subst), Body: substGenericTypes(node.Body, subst)}
+	case *Range:
+		return &Range{Sta: substGenericTypes(node.Sta, subst), End: substGenericTypes(node.End, subst)}
+	case *MethodCall:
+		args := make([]Expr, len(node.Args))
+		for i, a := range node.Args {
+			args[i] = substGenericTypes(a, subst)
+		}
+		return &MethodCall{Receiver: substGenericTypes(node.Receiver, subst), Method: node.Method, Args: args}
+	case *Call:
+		typeArgs := make([]Type, len(node.TypeArgs))
+		for i, t := range node.TypeArgs {
+			typeArgs[i] = substType(t, subst)
+		}
+		args := make([]Expr, len(node.Args))
+		for i, a := range node.Args {
+			args[i] = substGenericTypes(a, subst)
+		}
+		return &Call{Callee: substGenericTypes(node.Callee, subst), TypeArgs: typeArgs, Args: args}
+	default:
+		return expr
+	}
+}
+
+// substType recursively replaces any KindParam Type named in subst with its
+// concrete instantiation, reconstructing compound Types (pointers, tuples,
+// signatures, unions, named types) around the substituted pieces.
+func substType(t Type, subst map[string]Type) Type {
+	if t.data == nil {
+		return t
+	}
+	switch t.Kind() {
+	case KindParam:
+		if repl, ok := subst[t.Name()]; ok {
+			return repl
+		}
+		return t
+	case KindPointer:
+		return PointerOf(substType(t.Elem(), subst))
+	case KindTuple:
+		elems := make([]Type, len(t.Elems()))
+		for i, it := range t.Elems() {
+			elems[i] = substType(it, subst)
+		}
+		return TupleOf(elems...)
+	case KindSignature:
+		params := make([]Type, len(t.Params()))
+		for i, it := range t.Params() {
+			params[i] = substType(it, subst)
+		}
+		results := make([]Type, len(t.Results()))
+		for i, it := range t.Results() {
+			results[i] = substType(it, subst)
+		}
+		return SignatureOf(params, results)
+	case KindUnion:
+		elems := make([]Type, len(t.Elems()))
+		for i, it := range t.Elems() {
+			elems[i] = substType(it, subst)
+		}
+		return UnionOf(elems...)
+	case KindNamed:
+		return NamedOf(t.Name(), substType(t.Underlying(), subst))
+	default:
+		return t
+	}
+}
@@ -0,0 +1,56 @@
+package types
+
+import "testing"
+
+// TestOpMonomorphizeSpecializesPerConcreteType and
+// TestOpMonomorphizeReusesCachedInstantiation inspect Program's unexported
+// generics table directly, so they stay in-package rather than following
+// generic_test.go's package types_test + testify convention -- there's no
+// exported accessor for that table to assert against from outside.
+
+func identityGenericForInternalTest() *Generic {
+	return &Generic{
+		Name:       "identity",
+		TypeParams: []string{"T"},
+		Body:       Ref{Name: "arg0"},
+	}
+}
+
+func TestOpMonomorphizeSpecializesPerConcreteType(t *testing.T) {
+	program := &Program{}
+	program.Add(identityGenericForInternalTest())
+	program.Add(&Call{Callee: Var("identity"), Args: []Expr{IntLiteral(5)}})
+	program.Add(&Call{Callee: Var("identity"), Args: []Expr{StrLiteral("hi")}})
+
+	result, err := program.Run()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result != "hi" {
+		t.Fatalf("expected the last call's result to win with %q, got %v", "hi", result)
+	}
+	if _, ok := program.generics["identity$int"]; !ok {
+		t.Fatal("expected identity<int> to be interned under a mangled name")
+	}
+	if _, ok := program.generics["identity$str"]; !ok {
+		t.Fatal("expected identity<str> to be interned under a mangled name")
+	}
+}
+
+func TestOpMonomorphizeReusesCachedInstantiation(t *testing.T) {
+	program := &Program{}
+	program.Add(identityGenericForInternalTest())
+	program.Add(&Call{Callee: Var("identity"), Args: []Expr{IntLiteral(1)}})
+	program.Add(&Call{Callee: Var("identity"), Args: []Expr{IntLiteral(2)}})
+
+	result, err := program.Run()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result != int64(2) {
+		t.Fatalf("expected the second call's result to win with 2, got %v", result)
+	}
+	if len(program.generics) != 2 {
+		t.Fatalf("expected a single cached instantiation alongside the original declaration, got %d entries", len(program.generics))
+	}
+}
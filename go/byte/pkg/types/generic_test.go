@@ -0,0 +1,52 @@
+package types_test
+
+import (
+	"testing"
+
+	"axlab.dev/byte/pkg/types"
+	"github.com/stretchr/testify/require"
+)
+
+func identityGeneric() *types.Generic {
+	return &types.Generic{
+		Name:       "identity",
+		TypeParams: []string{"T"},
+		Body:       types.Ref{Name: "arg0"},
+	}
+}
+
+func TestOpMonomorphizeRequiresConsistentTypesAtEachPosition(t *testing.T) {
+	test := require.New(t)
+
+	defer func() {
+		test.NotNil(recover(), "expected a panic: the Call is never solved, so Compile should reject it")
+	}()
+
+	program := &types.Program{}
+	program.Add(&types.Generic{
+		Name:       "first",
+		TypeParams: []string{"T", "T"},
+		Body:       types.Ref{Name: "arg0"},
+	})
+	program.Add(&types.Call{
+		Callee: types.Var("first"),
+		Args:   []types.Expr{types.IntLiteral(1), types.StrLiteral("mismatch")},
+	})
+	program.Run()
+}
+
+func TestOpMonomorphizeHonorsExplicitTypeArgs(t *testing.T) {
+	test := require.New(t)
+
+	program := &types.Program{}
+	program.Add(identityGeneric())
+	program.Add(&types.Call{
+		Callee:   types.Var("identity"),
+		TypeArgs: []types.Type{types.TypeInt},
+		Args:     []types.Expr{types.IntLiteral(42)},
+	})
+
+	result, err := program.Run()
+	test.NoError(err)
+	test.Equal(int64(42), result)
+}
@@ -0,0 +1,223 @@
+package types
+
+import "fmt"
+
+// Method is a function attached to a NamedType's method set. It has no
+// parameter names of its own: Body refers to the receiver and arguments
+// positionally, as Ref{Name: "self"} and Ref{Name: "arg0"}, Ref{Name:
+// "arg1"}, ... -- OpMethodCall binds those names with a Decl per call site
+// before running Body, the same way OpForEach binds its loop variable with
+// a Decl before running a loop body.
+type Method struct {
+	Receiver Type
+	Params   []Type
+	Results  []Type
+	Body     Expr
+}
+
+// NamedType declares `type Name = Underlying`, along with whatever methods
+// are defined on it. OpDecl registers it in program.types the same way it
+// registers a *Decl in program.vars.
+type NamedType struct {
+	Name       string
+	Underlying Type
+	Methods    map[string]Method
+}
+
+func (me *NamedType) IsSolved(program *Program) bool {
+	decl, ok := program.types[me.Name]
+	return ok && decl == me
+}
+
+func (me *NamedType) Type() Type {
+	return NamedOf(me.Name, me.Underlying)
+}
+
+func (me *NamedType) Visit(fn func(*Expr)) {}
+
+func (me *NamedType) Compile(program *Program) Exec {
+	return func(*Thread) interface{} {
+		return nil
+	}
+}
+
+func (me *NamedType) Emit(ctx *EmitContext) (string, error) {
+	return "", fmt.Errorf("named type declaration `%s` has no emitted statement form yet", me.Name)
+}
+
+// TypeRef is what a Var naming a declared NamedType resolves to via OpBind,
+// mirroring how a Var naming a regular variable resolves to a Ref.
+type TypeRef struct {
+	Name   string
+	Target *NamedType
+}
+
+func (me TypeRef) IsSolved(program *Program) bool {
+	return true
+}
+
+func (me TypeRef) Type() Type {
+	return me.Target.Type()
+}
+
+func (me TypeRef) Visit(fn func(*Expr)) {}
+
+func (me TypeRef) Compile(program *Program) Exec {
+	return func(*Thread) interface{} {
+		return nil
+	}
+}
+
+func (me TypeRef) Emit(ctx *EmitContext) (string, error) {
+	return me.Name, nil
+}
+
+// MethodCall is the surface syntax for `Receiver.Method(Args...)`, unsolved
+// until OpMethodCall can resolve Method against the receiver's type.
+type MethodCall struct {
+	Receiver Expr
+	Method   string
+	Args     []Expr
+}
+
+func (me *MethodCall) IsSolved(program *Program) bool {
+	return false
+}
+
+func (me *MethodCall) Type() Type {
+	return TypeAny
+}
+
+func (me *MethodCall) Visit(fn func(*Expr)) {
+	fn(&me.Receiver)
+	for i := range me.Args {
+		fn(&me.Args[i])
+	}
+}
+
+func (me *MethodCall) Compile(program *Program) Exec {
+	panic(fmt.Sprintf("method call `.%s` was not resolved by OpMethodCall", me.Method))
+}
+
+func (me *MethodCall) Emit(ctx *EmitContext) (string, error) {
+	return "", fmt.Errorf("method call `.%s` was not resolved by OpMethodCall", me.Method)
+}
+
+// OpMethodCall rewrites a MethodCall into a direct call once its receiver's
+// type is solved and names a NamedType with a matching method: a Code that
+// binds the receiver and arguments under fresh per-call-site names and then
+// runs a clone of the method's Body.
+type OpMethodCall struct{}
+
+func (me OpMethodCall) Apply(program *Program, expr Expr) (out Expr, ok bool) {
+	call, ok := expr.(*MethodCall)
+	if !ok {
+		return nil, false
+	}
+	if !call.Receiver.IsSolved(program) {
+		return nil, false
+	}
+
+	recvType := call.Receiver.Type()
+	if recvType.Kind() != KindNamed {
+		return nil, false
+	}
+
+	named, ok := program.types[recvType.Name()]
+	if !ok {
+		return nil, false
+	}
+
+	method, ok := named.Methods[call.Method]
+	if !ok {
+		panic(fmt.Sprintf("type `%s` has no method `%s`", recvType.Name(), call.Method))
+	}
+	if len(call.Args) != len(method.Params) {
+		panic(fmt.Sprintf("method `%s.%s` expects %d arguments, got %d", recvType.Name(), call.Method, len(method.Params), len(call.Args)))
+	}
+
+	program.callSeq++
+	suffix := fmt.Sprintf("$%d", program.callSeq)
+
+	locals := map[string]*Decl{
+		"self": {Name: "self" + suffix, Value: asType{Expr: call.Receiver, typ: method.Receiver}},
+	}
+	code := make([]Expr, 0, len(call.Args)+2)
+	code = append(code, locals["self"])
+	for i, arg := range call.Args {
+		name := fmt.Sprintf("arg%d", i)
+		decl := &Decl{Name: name + suffix, Value: arg}
+		locals[name] = decl
+		code = append(code, decl)
+	}
+	code = append(code, renameBoundVars(method.Body, suffix, locals))
+
+	return &Code{Expr: code}, true
+}
+
+// asType wraps an already-solved Expr, overriding its static Type -- used to
+// give "self" the method's declared Receiver type (e.g. the underlying
+// TypeInt) rather than the receiver expression's own Named type, since
+// OpAdd/OpLess and friends type-check their operands against the former.
+type asType struct {
+	Expr
+	typ Type
+}
+
+func (me asType) Type() Type { return me.typ }
+
+// renameBoundVars clones expr, renaming every Ref naming "self" or "argN" to
+// add suffix and pointing it at the Decl locals created for this call site
+// -- so each call gets its own copy of the method's locals instead of
+// colliding with (or re-declaring) another call's, and Ref.Type() has a
+// Target to consult.
+func renameBoundVars(expr Expr, suffix string, locals map[string]*Decl) Expr {
+	switch node := expr.(type) {
+	case Ref:
+		if decl, ok := locals[node.Name]; ok {
+			node.Name = decl.Name
+			node.Target = decl
+		}
+		return node
+	case *Code:
+		out := make([]Expr, len(node.Expr))
+		for i, it := range node.Expr {
+			out[i] = renameBoundVars(it, suffix, locals)
+		}
+		return &Code{Expr: out}
+	case *Print:
+		out := make([]Expr, len(node.List))
+		for i, it := range node.List {
+			out[i] = renameBoundVars(it, suffix, locals)
+		}
+		return &Print{List: out}
+	case *Decl:
+		return &Decl{Name: node.Name, Value: renameBoundVars(node.Value, suffix, locals)}
+	case *Set:
+		return &Set{Name: node.Name, Expr: renameBoundVars(node.Expr, suffix, locals)}
+	case *OpAdd:
+		return &OpAdd{Lhs: renameBoundVars(node.Lhs, suffix, locals), Rhs: renameBoundVars(node.Rhs, suffix, locals)}
+	case *OpLess:
+		return &OpLess{Lhs: renameBoundVars(node.Lhs, suffix, locals), Rhs: renameBoundVars(node.Rhs, suffix, locals)}
+	case *While:
+		return &While{Cond: renameBoundVars(node.Cond, suffix, locals), Body: renameBoundVars(node.Body, suffix, locals)}
+	case *ForEach:
+		return &ForEach{Name: node.Name, From: renameBoundVars(node.From, suffix, locals), Body: renameBoundVars(node.Body, suffix, locals)}
+	case *Range:
+		return &Range{Sta: renameBoundVars(node.Sta, suffix, locals), End: renameBoundVars(node.End, suffix, locals)}
+	case *MethodCall:
+		args := make([]Expr, len(node.Args))
+		for i, it := range node.Args {
+			args[i] = renameBoundVars(it, suffix, locals)
+		}
+		return &MethodCall{Receiver: renameBoundVars(node.Receiver, suffix, locals), Method: node.Method, Args: args}
+	case *Call:
+		args := make([]Expr, len(node.Args))
+		for i, it := range node.Args {
+			args[i] = renameBoundVars(it, suffix, locals)
+		}
+		return &Call{Callee: renameBoundVars(node.Callee, suffix, locals), TypeArgs: node.TypeArgs, Args: args}
+	default:
+		return expr
+	}
+}
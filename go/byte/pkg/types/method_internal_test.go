@@ -0,0 +1,44 @@
+package types
+
+import "testing"
+
+// TestVarResolvesToDeclaredNamedType inspects Program's private vars/types
+// tables directly, so it stays in-package rather than following
+// method_test.go's package types_test + testify convention -- there's no
+// exported accessor for either table to assert against from outside.
+func TestVarResolvesToDeclaredNamedType(t *testing.T) {
+	program := &Program{}
+	program.Add(counterTypeForInternalTest())
+	program.Add(&Decl{Name: "n", Value: Var("Counter")})
+
+	if _, err := program.Run(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	decl, ok := program.types["Counter"]
+	if !ok {
+		t.Fatal("expected OpDecl to register Counter in program.types")
+	}
+	ref, ok := program.vars["n"].Value.(*TypeRef)
+	if !ok {
+		t.Fatalf("expected Var(\"Counter\") to resolve to a *TypeRef, got %T", program.vars["n"].Value)
+	}
+	if ref.Target != decl {
+		t.Fatal("expected the TypeRef to point at the registered NamedType")
+	}
+}
+
+func counterTypeForInternalTest() *NamedType {
+	return &NamedType{
+		Name:       "Counter",
+		Underlying: TypeInt,
+		Methods: map[string]Method{
+			"Add": {
+				Receiver: TypeInt,
+				Params:   []Type{TypeInt},
+				Results:  []Type{TypeInt},
+				Body:     &OpAdd{Lhs: Ref{Name: "self"}, Rhs: Ref{Name: "arg0"}},
+			},
+		},
+	}
+}
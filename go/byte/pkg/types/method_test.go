@@ -0,0 +1,123 @@
+package types_test
+
+import (
+	"testing"
+
+	"axlab.dev/byte/pkg/types"
+	"github.com/stretchr/testify/require"
+)
+
+// namedValue wraps an already-solved Expr, overriding its static Type --
+// stands in for a real Counter-producing constructor, which no chunk in
+// this package adds yet.
+type namedValue struct {
+	types.Expr
+	typ types.Type
+}
+
+func (me namedValue) Type() types.Type { return me.typ }
+
+func counterType() *types.NamedType {
+	return &types.NamedType{
+		Name:       "Counter",
+		Underlying: types.TypeInt,
+		Methods: map[string]types.Method{
+			"Add": {
+				Receiver: types.TypeInt,
+				Params:   []types.Type{types.TypeInt},
+				Results:  []types.Type{types.TypeInt},
+				Body:     &types.OpAdd{Lhs: types.Ref{Name: "self"}, Rhs: types.Ref{Name: "arg0"}},
+			},
+		},
+	}
+}
+
+func TestOpMethodCallRewritesToDirectCall(t *testing.T) {
+	test := require.New(t)
+
+	program := &types.Program{}
+	program.Add(counterType())
+	program.Add(&types.MethodCall{
+		Receiver: namedValue{Expr: types.IntLiteral(5), typ: types.NamedOf("Counter", types.TypeInt)},
+		Method:   "Add",
+		Args:     []types.Expr{types.IntLiteral(3)},
+	})
+
+	result, err := program.Run()
+	test.NoError(err)
+	test.Equal(int64(8), result, "expected 5.Add(3) == 8")
+}
+
+func TestOpMethodCallSupportsRepeatedCalls(t *testing.T) {
+	test := require.New(t)
+
+	program := &types.Program{}
+	program.Add(counterType())
+	program.Add(&types.MethodCall{
+		Receiver: namedValue{Expr: types.IntLiteral(1), typ: types.NamedOf("Counter", types.TypeInt)},
+		Method:   "Add",
+		Args:     []types.Expr{types.IntLiteral(1)},
+	})
+	program.Add(&types.MethodCall{
+		Receiver: namedValue{Expr: types.IntLiteral(10), typ: types.NamedOf("Counter", types.TypeInt)},
+		Method:   "Add",
+		Args:     []types.Expr{types.IntLiteral(10)},
+	})
+
+	result, err := program.Run()
+	test.NoError(err)
+	test.Equal(int64(20), result, "expected the last call's result to win with 20")
+}
+
+func TestOpMethodCallPanicsForUnknownMethod(t *testing.T) {
+	test := require.New(t)
+
+	defer func() {
+		test.NotNil(recover(), "expected a panic for an undeclared method")
+	}()
+
+	program := &types.Program{}
+	program.Add(&types.NamedType{Name: "Counter", Underlying: types.TypeInt, Methods: map[string]types.Method{}})
+	program.Add(&types.MethodCall{
+		Receiver: namedValue{Expr: types.IntLiteral(5), typ: types.NamedOf("Counter", types.TypeInt)},
+		Method:   "Missing",
+	})
+	program.Run()
+}
+
+func TestOpMethodCallRenamesBoundVarsInsideLoops(t *testing.T) {
+	test := require.New(t)
+
+	sumType := &types.NamedType{
+		Name:       "Looper",
+		Underlying: types.TypeInt,
+		Methods: map[string]types.Method{
+			"SumTo": {
+				Receiver: types.TypeInt,
+				Params:   []types.Type{types.TypeInt},
+				Results:  []types.Type{types.TypeInt},
+				Body: &types.Code{Expr: []types.Expr{
+					&types.Decl{Name: "total", Value: types.IntLiteral(0)},
+					&types.ForEach{
+						Name: "i",
+						From: &types.Range{Sta: types.Ref{Name: "self"}, End: types.Ref{Name: "arg0"}},
+						Body: &types.Set{Name: "total", Expr: &types.OpAdd{Lhs: types.Var("total"), Rhs: types.Var("i")}},
+					},
+					types.Var("total"),
+				}},
+			},
+		},
+	}
+
+	program := &types.Program{}
+	program.Add(sumType)
+	program.Add(&types.MethodCall{
+		Receiver: namedValue{Expr: types.IntLiteral(3), typ: types.NamedOf("Looper", types.TypeInt)},
+		Method:   "SumTo",
+		Args:     []types.Expr{types.IntLiteral(6)},
+	})
+
+	result, err := program.Run()
+	test.NoError(err)
+	test.Equal(int64(12), result, "expected self=3..6 (3+4+5) == 12")
+}
@@ -0,0 +1,213 @@
+package types
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// evalTypes and nativeTypes canonicalize the mapping between a Go
+// reflect.Type and its Type counterpart, so the same native type always
+// maps to the same Type (and back) instead of growing a fresh descriptive
+// Type on every lookup -- mirroring the caches the old exp/eval bridge kept
+// for the same reason.
+var (
+	nativeRw    sync.RWMutex
+	evalTypes   = map[reflect.Type]Type{}
+	nativeTypes = map[Type]reflect.Type{}
+)
+
+// TypeFromNative returns the Type for rt, registering the mapping (in both
+// directions, see NativeType) the first time rt is seen.
+func TypeFromNative(rt reflect.Type) Type {
+	nativeRw.RLock()
+	typ, ok := evalTypes[rt]
+	nativeRw.RUnlock()
+	if ok {
+		return typ
+	}
+
+	typ = describeNative(rt)
+
+	nativeRw.Lock()
+	defer nativeRw.Unlock()
+	evalTypes[rt] = typ
+	if _, exists := nativeTypes[typ]; !exists {
+		nativeTypes[typ] = rt
+	}
+	return typ
+}
+
+// NativeType returns the reflect.Type a previous TypeFromNative call
+// registered for typ, if any (the first one registered, when several native
+// types map to typ).
+func NativeType(typ Type) (reflect.Type, bool) {
+	nativeRw.RLock()
+	defer nativeRw.RUnlock()
+	rt, ok := nativeTypes[typ]
+	return rt, ok
+}
+
+// describeNative derives a Type for rt from its reflect.Kind, recursing
+// into element/key/result types for composites so distinct native types
+// still get distinct (if unstructured) Type values -- e.g. `[]int` and
+// `[]string` don't collide on a single "slice" Type.
+func describeNative(rt reflect.Type) Type {
+	switch rt.Kind() {
+	case reflect.Bool:
+		return TypeBool
+	case reflect.String:
+		return TypeStr
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return TypeInt
+	case reflect.Array:
+		return NamedOf(fmt.Sprintf("[%d]%s", rt.Len(), TypeFromNative(rt.Elem())), TypeAny)
+	case reflect.Slice:
+		return NamedOf(fmt.Sprintf("[]%s", TypeFromNative(rt.Elem())), TypeAny)
+	case reflect.Ptr:
+		return PointerOf(TypeFromNative(rt.Elem()))
+	case reflect.Map:
+		return NamedOf(fmt.Sprintf("map[%s]%s", TypeFromNative(rt.Key()), TypeFromNative(rt.Elem())), TypeAny)
+	case reflect.Chan:
+		return NamedOf(fmt.Sprintf("chan %s", TypeFromNative(rt.Elem())), TypeAny)
+	default:
+		return NamedOf(rt.String(), TypeAny)
+	}
+}
+
+// NativeValue wraps a native Go value as a constant Expr, so ValueFromNative
+// results can be used anywhere an Expr is expected -- e.g. as a NativeCall
+// argument -- without needing a user-facing literal syntax for it.
+type NativeValue struct {
+	Value reflect.Value
+}
+
+// ValueFromNative wraps v as an already-solved Expr.
+func ValueFromNative(v reflect.Value) Expr {
+	return NativeValue{Value: v}
+}
+
+func (me NativeValue) IsSolved(program *Program) bool {
+	return true
+}
+
+func (me NativeValue) Type() Type {
+	return TypeFromNative(me.Value.Type())
+}
+
+func (me NativeValue) Visit(fn func(*Expr)) {}
+
+func (me NativeValue) Compile(program *Program) Exec {
+	val := me.Value
+	return func(*Thread) interface{} {
+		return val.Interface()
+	}
+}
+
+// Emit always fails: a wrapped native Go value has no source-level literal
+// to lower to in either Backend.
+func (me NativeValue) Emit(ctx *EmitContext) (string, error) {
+	return "", fmt.Errorf("native value of type %s has no source-level representation", me.Value.Type())
+}
+
+// NativeCall invokes a native Go function (Fn) with the evaluated Args,
+// type-checking the argument count (accounting for a variadic Fn) at
+// Compile time and converting each argument's result to Fn's declared
+// parameter type before the call.
+type NativeCall struct {
+	Fn   reflect.Value
+	Args []Expr
+}
+
+func (me *NativeCall) IsSolved(program *Program) bool {
+	for _, it := range me.Args {
+		if !it.IsSolved(program) {
+			return false
+		}
+	}
+	return true
+}
+
+func (me *NativeCall) Type() Type {
+	ft := me.Fn.Type()
+	switch ft.NumOut() {
+	case 0:
+		return TypeUnit
+	case 1:
+		return TypeFromNative(ft.Out(0))
+	default:
+		out := TypeFromNative(ft.Out(0))
+		for i := 1; i < ft.NumOut(); i++ {
+			out = TypeOr(out, TypeFromNative(ft.Out(i)))
+		}
+		return out
+	}
+}
+
+func (me *NativeCall) Visit(fn func(*Expr)) {
+	for i := range me.Args {
+		fn(&me.Args[i])
+	}
+}
+
+func (me *NativeCall) Compile(program *Program) Exec {
+	ft := me.Fn.Type()
+	if ft.IsVariadic() {
+		if len(me.Args) < ft.NumIn()-1 {
+			panic(fmt.Sprintf("native call: expected at least %d arguments, got %d", ft.NumIn()-1, len(me.Args)))
+		}
+	} else if len(me.Args) != ft.NumIn() {
+		panic(fmt.Sprintf("native call: expected %d arguments, got %d", ft.NumIn(), len(me.Args)))
+	}
+
+	args := make([]Exec, len(me.Args))
+	for i, it := range me.Args {
+		args[i] = it.Compile(program)
+	}
+
+	fn := me.Fn
+	fixedIn := ft.NumIn()
+	if ft.IsVariadic() {
+		fixedIn--
+	}
+
+	return func(rt *Thread) interface{} {
+		in := make([]reflect.Value, len(args))
+		for i, it := range args {
+			var paramType reflect.Type
+			if i < fixedIn {
+				paramType = ft.In(i)
+			} else {
+				paramType = ft.In(ft.NumIn() - 1).Elem()
+			}
+			in[i] = reflect.ValueOf(it(rt)).Convert(paramType)
+		}
+
+		out := fn.Call(in)
+		switch len(out) {
+		case 0:
+			return nil
+		case 1:
+			return out[0].Interface()
+		default:
+			result := make([]interface{}, len(out))
+			for i, it := range out {
+				result[i] = it.Interface()
+			}
+			return result
+		}
+	}
+}
+
+// Emit always fails: a native Go function has no portable call syntax to
+// lower to in either Backend.
+func (me *NativeCall) Emit(ctx *EmitContext) (string, error) {
+	return "", fmt.Errorf("native call to %s has no source-level representation", me.Fn.Type())
+}
+
+var _ = func() {
+	assertExpr(NativeValue{})
+	assertExpr(&NativeCall{})
+}
@@ -0,0 +1,73 @@
+package types_test
+
+import (
+	"reflect"
+	"testing"
+
+	"axlab.dev/byte/pkg/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTypeFromNativeIsStableAndDistinct(t *testing.T) {
+	test := require.New(t)
+
+	a := types.TypeFromNative(reflect.TypeOf(0))
+	b := types.TypeFromNative(reflect.TypeOf(0))
+	test.Equal(a, b, "expected repeated lookups of the same native type to agree")
+
+	ints := types.TypeFromNative(reflect.TypeOf([]int{}))
+	strs := types.TypeFromNative(reflect.TypeOf([]string{}))
+	test.NotEqual(ints, strs, "expected []int and []string to map to distinct types")
+
+	rt, ok := types.NativeType(ints)
+	test.True(ok)
+	test.Equal(reflect.TypeOf([]int{}), rt, "expected NativeType to recover the registered reflect.Type")
+}
+
+func TestNativeCallInvokesFunction(t *testing.T) {
+	test := require.New(t)
+
+	add := func(a, b int) int { return a + b }
+
+	call := &types.NativeCall{
+		Fn:   reflect.ValueOf(add),
+		Args: []types.Expr{types.IntLiteral(3), types.IntLiteral(4)},
+	}
+
+	program := &types.Program{}
+	test.True(call.IsSolved(program), "expected a call over literal arguments to be solved")
+
+	exec := call.Compile(program)
+	result := exec(&types.Thread{})
+	test.Equal(7, result)
+}
+
+func TestNativeCallHandlesVariadic(t *testing.T) {
+	test := require.New(t)
+
+	sum := func(nums ...int) int {
+		total := 0
+		for _, n := range nums {
+			total += n
+		}
+		return total
+	}
+
+	call := &types.NativeCall{
+		Fn:   reflect.ValueOf(sum),
+		Args: []types.Expr{types.IntLiteral(1), types.IntLiteral(2), types.IntLiteral(3)},
+	}
+
+	exec := call.Compile(&types.Program{})
+	result := exec(&types.Thread{})
+	test.Equal(6, result)
+}
+
+func TestValueFromNativeRoundTrips(t *testing.T) {
+	test := require.New(t)
+
+	expr := types.ValueFromNative(reflect.ValueOf("hello"))
+	exec := expr.Compile(&types.Program{})
+	test.Equal("hello", exec(&types.Thread{}))
+	test.Equal(types.TypeStr, expr.Type())
+}
@@ -0,0 +1,105 @@
+package types
+
+import "fmt"
+
+// Thread carries the mutable state of a single Program execution -- the
+// declared variables' current values -- through the Exec closures Compile
+// produces, and is where a runtime error is raised via Abort so Program.Run
+// can recover it as a structured error instead of unwinding the process.
+type Thread struct {
+	vars map[string]interface{}
+}
+
+// Abort stops the running Exec chain and unwinds to the enclosing Try with
+// err, the way exp/eval's abort model works. It must only be called from
+// inside an Exec closure running under Try.
+func (th *Thread) Abort(err error) {
+	panic(threadAbort{err})
+}
+
+// Try runs fn, recovering an Abort raised anywhere underneath it and
+// returning it as err. A panic that isn't an Abort is a bug in an Exec
+// closure (or whatever it called), not a runtime error the caller should
+// be expected to handle, so Try lets it keep unwinding.
+func (th *Thread) Try(fn func(*Thread)) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			if abort, ok := r.(threadAbort); ok {
+				err = abort.err
+			} else {
+				panic(r)
+			}
+		}
+	}()
+	fn(th)
+	return nil
+}
+
+// threadAbort wraps the error passed to Abort so Try's recover can tell a
+// deliberate abort apart from an unrelated panic.
+type threadAbort struct {
+	err error
+}
+
+func (a threadAbort) Error() string {
+	return a.err.Error()
+}
+
+// DivByZeroError is raised by an integer division or modulo by zero.
+type DivByZeroError struct {
+	Op string
+}
+
+func (e *DivByZeroError) Error() string {
+	return fmt.Sprintf("division by zero in %s", e.Op)
+}
+
+// NilPointerError is raised by dereferencing a nil Ref value.
+type NilPointerError struct {
+	Op string
+}
+
+func (e *NilPointerError) Error() string {
+	return fmt.Sprintf("nil pointer dereference in %s", e.Op)
+}
+
+// IndexError is raised by an out-of-bounds array/slice index.
+type IndexError struct {
+	Index, Len int
+}
+
+func (e *IndexError) Error() string {
+	return fmt.Sprintf("index %d out of bounds for length %d", e.Index, e.Len)
+}
+
+// SliceError is raised by an invalid slice range (e.g. Sta > End, or either
+// bound out of bounds).
+type SliceError struct {
+	Sta, End, Len int
+}
+
+func (e *SliceError) Error() string {
+	return fmt.Sprintf("slice [%d:%d] out of bounds for length %d", e.Sta, e.End, e.Len)
+}
+
+// KeyError is raised by looking up a missing key in a map-like value.
+type KeyError struct {
+	Key interface{}
+}
+
+func (e *KeyError) Error() string {
+	return fmt.Sprintf("key not found: %v", e.Key)
+}
+
+// TypeAssertError is raised when a value produced at runtime doesn't have
+// the Type its Expr statically claimed -- a type-checking bug in an
+// Operator, or a native value (see NativeCall) that doesn't match its
+// declared signature.
+type TypeAssertError struct {
+	Op       string
+	Expected Type
+}
+
+func (e *TypeAssertError) Error() string {
+	return fmt.Sprintf("%s: expected a value of type %s", e.Op, e.Expected)
+}
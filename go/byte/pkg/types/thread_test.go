@@ -0,0 +1,60 @@
+package types_test
+
+import (
+	"fmt"
+	"testing"
+
+	"axlab.dev/byte/pkg/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestThreadTryReturnsAbortedError(t *testing.T) {
+	test := require.New(t)
+
+	th := &types.Thread{}
+	err := th.Try(func(inner *types.Thread) {
+		inner.Abort(&types.DivByZeroError{Op: "/"})
+	})
+	test.Error(err, "expected Try to return the aborted error")
+	test.IsType(&types.DivByZeroError{}, err)
+}
+
+func TestThreadTryLetsOtherPanicsThrough(t *testing.T) {
+	test := require.New(t)
+
+	defer func() {
+		test.NotNil(recover(), "expected an unrelated panic to propagate past Try")
+	}()
+
+	th := &types.Thread{}
+	th.Try(func(inner *types.Thread) {
+		panic("not an abort")
+	})
+}
+
+// lyingIntExpr declares TypeInt but actually evaluates to a string, so it
+// can stand in for a buggy Operator/native bridge that lets a value slip
+// past the type system -- the scenario OpAdd/OpLess guard against at
+// runtime with a TypeAssertError.
+type lyingIntExpr struct{}
+
+func (lyingIntExpr) IsSolved(program *types.Program) bool { return true }
+func (lyingIntExpr) Type() types.Type                     { return types.TypeInt }
+func (lyingIntExpr) Visit(fn func(*types.Expr))           {}
+func (lyingIntExpr) Compile(program *types.Program) types.Exec {
+	return func(*types.Thread) interface{} { return "not an int" }
+}
+func (lyingIntExpr) Emit(ctx *types.EmitContext) (string, error) {
+	return "", fmt.Errorf("lyingIntExpr has no source-level representation")
+}
+
+func TestProgramRunSurfacesTypeAssertError(t *testing.T) {
+	test := require.New(t)
+
+	program := &types.Program{}
+	program.Add(&types.OpAdd{Lhs: lyingIntExpr{}, Rhs: types.IntLiteral(1)})
+
+	_, err := program.Run()
+	test.Error(err, "expected Run to surface a type error rather than panic")
+	test.IsType(&types.TypeAssertError{}, err)
+}
@@ -1,69 +1,285 @@
 package types
 
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Kind distinguishes the structural shape a Type describes.
+type Kind int
+
+const (
+	KindPrimitive Kind = iota
+	KindTuple
+	KindPointer
+	KindSignature
+	KindUnion
+	KindNamed
+	KindParam
+)
+
+// Type is a structural type value. Two Types built from the same shape
+// always compare equal with == -- see intern -- so code such as
+// EmitContext.TypeName can switch on the package-level singletons (TypeInt,
+// TypeStr, ...) directly; Compile's operand checks go through IsIdentical.
 type Type struct {
 	data *typeData
 }
 
-func Get(id TypeId) Type {
-	return Type{byId(id)}
+type typeData struct {
+	kind       Kind
+	name       string // primitive/named name
+	elem       Type   // pointer element
+	elems      []Type // tuple fields, union members (sorted by repr)
+	params     []Type // signature parameters
+	results    []Type // signature results
+	underlying Type   // named underlying type
+	repr       string
 }
 
-func Tuple(types ...Type) Type {
-	return Type{tupleOf(types...)}
+func (t Type) Kind() Kind {
+	return t.data.kind
 }
 
-func (t Type) Id() TypeId {
-	if t.data == nil {
-		return 0
-	}
-	return t.data.id
+func (t Type) Name() string {
+	return t.data.name
 }
 
-func (t Type) Name() string {
+func (t Type) Elem() Type {
+	return t.data.elem
+}
+
+func (t Type) Elems() []Type {
+	return t.data.elems
+}
+
+func (t Type) Params() []Type {
+	return t.data.params
+}
+
+func (t Type) Results() []Type {
+	return t.data.results
+}
+
+func (t Type) Underlying() Type {
+	return t.data.underlying
+}
+
+func (t Type) String() string {
 	if t.data == nil {
-		return ""
+		return "<nil>"
 	}
-	return t.data.name
+	return t.data.repr
 }
 
-func (t Type) Hash() TypeHash {
-	if t.data == nil {
-		return ""
+var (
+	internRw sync.RWMutex
+	interned = map[string]*typeData{}
+)
+
+// intern returns the canonical Type for data, registering it the first time
+// its shape is seen -- mirrors the hash-interning core.TypeMap does for
+// core.Type, scaled down to this package's fixed, closed set of Kinds. A
+// named type's repr is only its name, so its key also carries the underlying
+// type: the same name over two underlying types interns to two Types.
+func intern(data typeData) Type {
+	data.repr = reprOf(data)
+	key := data.repr
+	if data.kind == KindNamed {
+		key += " = " + data.underlying.String()
 	}
-	return t.data.hash
+	internRw.Lock()
+	defer internRw.Unlock()
+	if cur, ok := interned[key]; ok {
+		return Type{cur}
+	}
+	out := data
+	interned[key] = &out
+	return Type{&out}
 }
 
-func (t Type) Less(other Type) bool {
-	if t.data == nil {
-		return other.data != nil
+func reprOf(data typeData) string {
+	switch data.kind {
+	case KindPrimitive, KindNamed:
+		return data.name
+	case KindParam:
+		return "$" + data.name
+	case KindPointer:
+		return "*" + data.elem.String()
+	case KindTuple:
+		return "(" + joinTypes(data.elems, ", ") + ")"
+	case KindSignature:
+		return fmt.Sprintf("func(%s) (%s)", joinTypes(data.params, ", "), joinTypes(data.results, ", "))
+	case KindUnion:
+		return joinTypes(data.elems, "|")
+	default:
+		panic(fmt.Sprintf("type: unknown kind %d", data.kind))
 	}
-	return t.data.Less(other.data)
 }
 
-func (t Type) IsBuiltin() bool {
-	if t.data == nil {
-		return false
+func joinTypes(types []Type, sep string) string {
+	parts := make([]string, len(types))
+	for i, it := range types {
+		parts[i] = it.String()
 	}
-	return t.IsBuiltin()
+	return strings.Join(parts, sep)
 }
 
-func (t Type) String() string {
-	if t.data == nil {
-		return "<?>"
-	} else {
-		return t.data.repr
+var (
+	TypeUnit = primitive("()")
+	TypeAny  = primitive("???")
+	TypeInt  = primitive("int")
+	TypeStr  = primitive("str")
+	TypeBool = primitive("bool")
+)
+
+func primitive(name string) Type {
+	return intern(typeData{kind: KindPrimitive, name: name})
+}
+
+// PointerOf returns the Type for a pointer to elem.
+func PointerOf(elem Type) Type {
+	return intern(typeData{kind: KindPointer, elem: elem})
+}
+
+// TupleOf returns the Type for a tuple of elems, in order -- unlike UnionOf,
+// field order is significant and is not deduplicated.
+func TupleOf(elems ...Type) Type {
+	return intern(typeData{kind: KindTuple, elems: append([]Type{}, elems...)})
+}
+
+// SignatureOf returns the Type for a function taking params and returning
+// results.
+func SignatureOf(params, results []Type) Type {
+	return intern(typeData{
+		kind:    KindSignature,
+		params:  append([]Type{}, params...),
+		results: append([]Type{}, results...),
+	})
+}
+
+// NamedOf returns the Type for a named type with the given underlying type.
+func NamedOf(name string, underlying Type) Type {
+	return intern(typeData{kind: KindNamed, name: name, underlying: underlying})
+}
+
+// TypeParamOf returns the placeholder Type for a Generic's type parameter
+// named name -- it only ever appears inside a Generic's Body (e.g. as a
+// Method's Receiver or a NamedType's Underlying) before OpMonomorphize
+// substitutes it with a concrete Type for a given instantiation.
+func TypeParamOf(name string) Type {
+	return intern(typeData{kind: KindParam, name: name})
+}
+
+// UnionOf returns the Type for the union of items, flattening any nested
+// unions and deduplicating members so e.g. `int|int|str` and `str|int` both
+// collapse to the same two-member union. A union of a single distinct
+// member is that member itself.
+func UnionOf(items ...Type) Type {
+	members := map[string]Type{}
+	var flatten func(t Type)
+	flatten = func(t Type) {
+		if t.Kind() == KindUnion {
+			for _, it := range t.Elems() {
+				flatten(it)
+			}
+			return
+		}
+		members[t.String()] = t
+	}
+	for _, it := range items {
+		flatten(it)
+	}
+
+	keys := make([]string, 0, len(members))
+	for k := range members {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	if len(keys) == 1 {
+		return members[keys[0]]
 	}
+
+	elems := make([]Type, len(keys))
+	for i, k := range keys {
+		elems[i] = members[k]
+	}
+	return intern(typeData{kind: KindUnion, elems: elems})
 }
 
-type Value struct {
-	typ Type
-	val any
+// TypeOr returns the union of a and b -- kept as the name the rest of the
+// package (and Range.Type, NativeCall.Type) already calls this under.
+func TypeOr(a, b Type) Type {
+	return UnionOf(a, b)
 }
 
-func (v Value) Type() Type {
-	return v.typ
+// IsIdentical reports whether a and b describe the same structural type.
+// Canonical Types produced by this package's constructors already compare
+// equal with ==, but IsIdentical additionally recurses so it also handles
+// Types assembled by hand (e.g. a clone produced while substituting a
+// Generic's type parameters) without requiring the caller to re-intern
+// first.
+func IsIdentical(a, b Type) bool {
+	if a.data == b.data {
+		return true
+	}
+	if a.data == nil || b.data == nil || a.Kind() != b.Kind() {
+		return false
+	}
+
+	switch a.Kind() {
+	case KindPrimitive, KindParam:
+		return a.Name() == b.Name()
+	case KindPointer:
+		return IsIdentical(a.Elem(), b.Elem())
+	case KindTuple:
+		return identicalLists(a.Elems(), b.Elems())
+	case KindSignature:
+		return identicalLists(a.Params(), b.Params()) && identicalLists(a.Results(), b.Results())
+	case KindUnion:
+		return identicalSets(a.Elems(), b.Elems())
+	case KindNamed:
+		// identical underlying types, modulo name -- a named type is
+		// identical to another of the same underlying shape even if the
+		// program gave it a different name.
+		return IsIdentical(a.Underlying(), b.Underlying())
+	default:
+		return false
+	}
 }
 
-func (v Value) Any() any {
-	return v.val
+func identicalLists(a, b []Type) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !IsIdentical(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func identicalSets(a, b []Type) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	used := make([]bool, len(b))
+	for _, x := range a {
+		found := false
+		for i, y := range b {
+			if !used[i] && IsIdentical(x, y) {
+				used[i] = true
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
 }
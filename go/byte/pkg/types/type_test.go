@@ -0,0 +1,43 @@
+package types
+
+import "testing"
+
+func TestUnionOfDeduplicatesAndIgnoresOrder(t *testing.T) {
+	a := UnionOf(TypeInt, TypeInt, TypeStr)
+	b := UnionOf(TypeStr, TypeInt)
+	if a != b {
+		t.Fatalf("expected int|int|str and str|int to intern to the same Type, got %s and %s", a, b)
+	}
+	if !IsIdentical(a, b) {
+		t.Fatalf("expected %s to be identical to %s", a, b)
+	}
+	if len(a.Elems()) != 2 {
+		t.Fatalf("expected the union to have 2 members after dedup, got %d", len(a.Elems()))
+	}
+}
+
+func TestUnionOfSingleMemberIsThatMember(t *testing.T) {
+	if got := UnionOf(TypeInt, TypeInt); got != TypeInt {
+		t.Fatalf("expected a union of a single distinct member to collapse to it, got %s", got)
+	}
+}
+
+func TestIsIdenticalDistinguishesDifferentShapes(t *testing.T) {
+	if IsIdentical(TypeInt, TypeStr) {
+		t.Fatal("expected TypeInt and TypeStr to differ")
+	}
+	if IsIdentical(PointerOf(TypeInt), TypeInt) {
+		t.Fatal("expected *int and int to differ")
+	}
+	if !IsIdentical(PointerOf(TypeInt), PointerOf(TypeInt)) {
+		t.Fatal("expected *int to be identical to *int")
+	}
+}
+
+func TestIsIdenticalMatchesNamedTypesByUnderlying(t *testing.T) {
+	a := NamedOf("Meters", TypeInt)
+	b := NamedOf("Feet", TypeInt)
+	if !IsIdentical(a, b) {
+		t.Fatal("expected two named types with the same underlying type to be identical")
+	}
+}